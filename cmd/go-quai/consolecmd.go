@@ -0,0 +1,178 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/dominant-strategies/go-quai/cmd/utils"
+	"github.com/dominant-strategies/go-quai/node"
+	"github.com/dominant-strategies/go-quai/rpc"
+	"github.com/peterh/liner"
+	"gopkg.in/urfave/cli.v1"
+)
+
+var (
+	ConsoleExecFlag = cli.StringFlag{
+		Name:  "exec",
+		Usage: "Execute the given call, print its result and exit",
+	}
+	attachCommand = cli.Command{
+		Action:    utils.MigrateFlags(remoteConsole),
+		Name:      "attach",
+		Usage:     "Start an interactive console attached to a running node",
+		ArgsUsage: "[endpoint]",
+		Flags: []cli.Flag{
+			ConsoleExecFlag,
+		},
+		Category: "CONSOLE COMMANDS",
+		Description: `
+The attach command connects to the HTTP or WS endpoint of a running node (by
+default ` + defaultConsoleEndpoint + `) and opens a console issuing RPC calls
+against it, e.g.
+
+    > quai.blockNumber
+    > quai.getBlockByNumber("latest", false)
+    > admin.peers
+
+Calls are written as namespace.method, with the parameters given as JSON values
+between parentheses. Any namespace exposed by the endpoint may be used.`,
+	}
+)
+
+// defaultConsoleEndpoint is the endpoint attached to if none is given.
+var defaultConsoleEndpoint = fmt.Sprintf("http://%s:%d", node.DefaultHTTPHost, node.DefaultHTTPPort)
+
+// remoteConsole attaches an interactive console to a running node.
+func remoteConsole(ctx *cli.Context) error {
+	if ctx.NArg() > 1 {
+		utils.Fatalf("This command accepts at most one argument")
+	}
+	endpoint := defaultConsoleEndpoint
+	if ctx.NArg() == 1 {
+		endpoint = ctx.Args().First()
+	}
+	client, err := rpc.Dial(endpoint)
+	if err != nil {
+		utils.Fatalf("Unable to attach to remote node: %v", err)
+	}
+	defer client.Close()
+
+	if call := ctx.String(ConsoleExecFlag.Name); call != "" {
+		return evalConsoleCall(client, call)
+	}
+	modules, err := client.SupportedModules()
+	if err != nil {
+		return fmt.Errorf("failed to retrieve the modules of %s: %v", endpoint, err)
+	}
+	namespaces := make([]string, 0, len(modules))
+	for namespace := range modules {
+		namespaces = append(namespaces, namespace)
+	}
+	sort.Strings(namespaces)
+
+	fmt.Printf("Attached to %s\n", endpoint)
+	fmt.Printf("modules: %s\n", strings.Join(namespaces, " "))
+	fmt.Println("\nTo exit, press ctrl-d or type exit")
+
+	utils.Stdin.SetWordCompleter(consoleCompleter(namespaces))
+	for {
+		line, err := utils.Stdin.PromptInput("> ")
+		if err == liner.ErrPromptAborted {
+			continue // Ctrl-C discards the current line
+		}
+		if err == io.EOF {
+			fmt.Println()
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		line = strings.TrimSpace(line)
+		switch line {
+		case "":
+			continue
+		case "exit", "quit":
+			return nil
+		case "help":
+			fmt.Printf("modules: %s\n", strings.Join(namespaces, " "))
+			fmt.Println(`Call a method with namespace.method(param, ...), e.g. quai.getBlockByNumber("latest", false)`)
+			continue
+		}
+		utils.Stdin.AppendHistory(line)
+		if err := evalConsoleCall(client, line); err != nil {
+			fmt.Printf("Error: %v\n", err)
+		}
+	}
+}
+
+// evalConsoleCall issues the RPC call written on a console line, and prints its
+// result.
+func evalConsoleCall(client *rpc.Client, line string) error {
+	method, params, err := parseConsoleCall(line)
+	if err != nil {
+		return err
+	}
+	var result json.RawMessage
+	if err := client.Call(&result, method, params...); err != nil {
+		return err
+	}
+	if len(result) == 0 {
+		result = json.RawMessage("null")
+	}
+	out, err := json.MarshalIndent(result, "", "  ")
+	if err != nil {
+		return err
+	}
+	fmt.Println(string(out))
+	return nil
+}
+
+// parseConsoleCall splits a console line of the form namespace.method(params)
+// into the name of the RPC method and its parameters.
+func parseConsoleCall(line string) (string, []interface{}, error) {
+	name, args := line, ""
+	if i := strings.IndexByte(line, '('); i >= 0 {
+		if !strings.HasSuffix(line, ")") {
+			return "", nil, errors.New("missing closing parenthesis")
+		}
+		name, args = strings.TrimSpace(line[:i]), line[i+1:len(line)-1]
+	}
+	if name == "" || strings.ContainsAny(name, " \t") {
+		return "", nil, fmt.Errorf("invalid method %q", name)
+	}
+	method := strings.Replace(name, ".", "_", 1)
+	if !strings.Contains(method, "_") {
+		return "", nil, fmt.Errorf("method %q has no namespace", name)
+	}
+	var raw []json.RawMessage
+	if err := json.Unmarshal([]byte("["+args+"]"), &raw); err != nil {
+		return "", nil, fmt.Errorf("invalid parameters: %v", err)
+	}
+	params := make([]interface{}, len(raw))
+	for i, param := range raw {
+		params[i] = param
+	}
+	return method, params, nil
+}
+
+// consoleCompleter completes the namespace of the call being typed.
+func consoleCompleter(namespaces []string) utils.WordCompleter {
+	return func(line string, pos int) (string, []string, string) {
+		start := strings.LastIndexAny(line[:pos], " (,") + 1
+		word := line[start:pos]
+		if strings.Contains(word, ".") {
+			return line, nil, ""
+		}
+		var candidates []string
+		for _, namespace := range namespaces {
+			if strings.HasPrefix(namespace, word) {
+				candidates = append(candidates, namespace+".")
+			}
+		}
+		return line[:start], candidates, line[pos:]
+	}
+}
@@ -52,13 +52,21 @@ var (
 	nodeFlags = []cli.Flag{
 		configFileFlag,
 		utils.AncientFlag,
+		utils.ChainDataDirFlag,
+		utils.NodeDatabaseDirFlag,
 		utils.BloomFilterSizeFlag,
 		utils.BootnodesFlag,
+		utils.BootnodesFallbackFlag,
+		utils.BootnodesMinLiveFlag,
+		utils.ControlPeersFlag,
 		utils.CacheDatabaseFlag,
 		utils.CacheFlag,
 		utils.CacheGCFlag,
 		utils.CacheNoPrefetchFlag,
 		utils.CachePreimagesFlag,
+		utils.CacheCanonicalFlag,
+		utils.CacheMissingBlocksFlag,
+		utils.CacheMissingBlockTTLFlag,
 		utils.CacheSnapshotFlag,
 		utils.CacheTrieFlag,
 		utils.CacheTrieJournalFlag,
@@ -90,7 +98,12 @@ var (
 		utils.LogToStdOutFlag,
 		utils.MaxPeersFlag,
 		utils.MaxPendingPeersFlag,
+		utils.DialTimeoutFlag,
+		utils.HandshakeTimeoutFlag,
+		utils.WriteTimeoutFlag,
+		utils.ReadTimeoutFlag,
 		utils.MinFreeDiskSpaceFlag,
+		utils.ShutdownTimeoutFlag,
 		utils.MinerEtherbaseFlag,
 		utils.MinerGasPriceFlag,
 		utils.NATFlag,
@@ -106,11 +119,18 @@ var (
 		utils.QuaiStatsURLFlag,
 		utils.SendFullStatsFlag,
 		utils.RegionFlag,
+		utils.RequestLogSampleFlag,
+		utils.BlockProvenanceFlag,
+		utils.ChainCheckFlag,
+		utils.FirstSeenFlag,
 		utils.ShowColorsFlag,
 		utils.SlicesRunningFlag,
 		utils.SnapshotFlag,
 		utils.SubUrls,
 		utils.SyncModeFlag,
+		utils.SyncTargetFlag,
+		utils.SyncTipTimeoutFlag,
+		utils.SyncRequestTimeoutFlag,
 		utils.TxLookupLimitFlag,
 		utils.TxPoolAccountQueueFlag,
 		utils.TxPoolAccountSlotsFlag,
@@ -145,6 +165,7 @@ var (
 		utils.LegacyRPCListenAddrFlag,
 		utils.LegacyRPCPortFlag,
 		utils.LegacyRPCVirtualHostsFlag,
+		utils.RPCAPIKeysFlag,
 		utils.RPCGlobalGasCapFlag,
 		utils.RPCGlobalTxFeeCapFlag,
 		utils.WSAllowedOriginsFlag,
@@ -183,10 +204,14 @@ func init() {
 		exportPreimagesCommand,
 		dumpCommand,
 		dumpGenesisCommand,
+		// See consolecmd.go:
+		attachCommand,
 		// See misccmd.go:
 		versionCommand,
 		versionCheckCommand,
 		licenseCommand,
+		// See rpcbench.go
+		rpcbenchCommand,
 		// See config.go
 		dumpConfigCommand,
 		// See snapshot.go
@@ -278,6 +303,10 @@ func quai(ctx *cli.Context) error {
 
 	startNode(ctx, stack, backend)
 	stack.Wait()
+	if code := utils.ShutdownExitCode(stack); code != 0 {
+		debug.Exit() // ensure trace and CPU profile data is flushed.
+		os.Exit(code)
+	}
 	return nil
 }
 
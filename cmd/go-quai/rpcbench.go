@@ -0,0 +1,236 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/rand"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dominant-strategies/go-quai/cmd/utils"
+	"github.com/dominant-strategies/go-quai/common/hexutil"
+	"github.com/dominant-strategies/go-quai/rpc"
+	"gopkg.in/urfave/cli.v1"
+)
+
+var (
+	BenchURLFlag = cli.StringFlag{
+		Name:  "bench.url",
+		Usage: "HTTP or WS endpoint of the node to benchmark",
+		Value: "http://127.0.0.1:8545",
+	}
+	BenchDurationFlag = cli.DurationFlag{
+		Name:  "bench.duration",
+		Usage: "Duration of the benchmark",
+		Value: 30 * time.Second,
+	}
+	BenchConcurrencyFlag = cli.IntFlag{
+		Name:  "bench.concurrency",
+		Usage: "Number of concurrent clients issuing calls",
+		Value: 16,
+	}
+	BenchMixFlag = cli.StringFlag{
+		Name:  "bench.mix",
+		Usage: "Comma separated list of method=weight pairs defining the mix of calls",
+		Value: "quai_blockNumber=4,quai_getBlockByNumber=4,quai_getLogs=1,quai_chainId=1",
+	}
+	BenchRangeFlag = cli.Uint64Flag{
+		Name:  "bench.range",
+		Usage: "Number of blocks below the head to pick the queried blocks from",
+		Value: 1000,
+	}
+	rpcbenchCommand = cli.Command{
+		Action: utils.MigrateFlags(rpcbench),
+		Name:   "rpcbench",
+		Usage:  "Load test the RPC endpoint of a node",
+		Flags: []cli.Flag{
+			BenchURLFlag,
+			BenchDurationFlag,
+			BenchConcurrencyFlag,
+			BenchMixFlag,
+			BenchRangeFlag,
+		},
+		Category: "MISCELLANEOUS COMMANDS",
+		Description: `
+The rpcbench command issues a weighted mix of RPC calls against the given
+endpoint from concurrent clients for the configured duration, and reports the
+throughput and latency percentiles of every method.
+
+Block numbers of queried blocks are picked at random below the head of the node.
+Methods without a known parameter set are called without parameters.`,
+	}
+)
+
+// benchParams returns the parameters of a benchmarked call to the method, with
+// the queried block picked at random from the given range.
+func benchParams(method string, head, span uint64) []interface{} {
+	number := head
+	if span > 0 && head > 0 {
+		if span > head {
+			span = head
+		}
+		number = head - uint64(rand.Int63n(int64(span)))
+	}
+	switch method {
+	case "quai_getBlockByNumber", "eth_getBlockByNumber":
+		return []interface{}{hexutil.EncodeUint64(number), false}
+	case "quai_getHeaderByNumber":
+		return []interface{}{hexutil.EncodeUint64(number)}
+	case "quai_getLogs", "eth_getLogs":
+		return []interface{}{map[string]interface{}{
+			"fromBlock": hexutil.EncodeUint64(number),
+			"toBlock":   hexutil.EncodeUint64(number),
+		}}
+	case "quai_getBlockTransactionCountByNumber", "eth_getBlockTransactionCountByNumber":
+		return []interface{}{hexutil.EncodeUint64(number)}
+	}
+	return nil
+}
+
+// benchMix is a weighted set of methods to pick benchmarked calls from.
+type benchMix struct {
+	methods []string
+	weights []int // Cumulative weights
+}
+
+func parseBenchMix(mix string) (*benchMix, error) {
+	m := new(benchMix)
+	total := 0
+	for _, entry := range utils.SplitAndTrim(mix) {
+		method, weight := entry, 1
+		if i := strings.IndexByte(entry, '='); i >= 0 {
+			w, err := strconv.Atoi(entry[i+1:])
+			if err != nil || w <= 0 {
+				return nil, fmt.Errorf("invalid weight in %q", entry)
+			}
+			method, weight = entry[:i], w
+		}
+		total += weight
+		m.methods = append(m.methods, method)
+		m.weights = append(m.weights, total)
+	}
+	if total == 0 {
+		return nil, errors.New("empty call mix")
+	}
+	return m, nil
+}
+
+func (m *benchMix) pick() string {
+	n := rand.Intn(m.weights[len(m.weights)-1])
+	return m.methods[sort.SearchInts(m.weights, n+1)]
+}
+
+// benchStats are the results collected for a single method.
+type benchStats struct {
+	latencies []time.Duration
+	errors    int
+}
+
+func rpcbench(ctx *cli.Context) error {
+	mix, err := parseBenchMix(ctx.String(BenchMixFlag.Name))
+	if err != nil {
+		return err
+	}
+	var (
+		url         = ctx.String(BenchURLFlag.Name)
+		duration    = ctx.Duration(BenchDurationFlag.Name)
+		concurrency = ctx.Int(BenchConcurrencyFlag.Name)
+		span        = ctx.Uint64(BenchRangeFlag.Name)
+	)
+	if concurrency < 1 {
+		return errors.New("concurrency must be at least 1")
+	}
+	client, err := rpc.Dial(url)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	var head hexutil.Uint64
+	if err := client.Call(&head, "quai_blockNumber"); err != nil {
+		return fmt.Errorf("failed to retrieve the head of %s: %v", url, err)
+	}
+	fmt.Printf("Benchmarking %s at block %d with %d clients for %v\n", url, uint64(head), concurrency, duration)
+
+	var (
+		lock  sync.Mutex
+		stats = make(map[string]*benchStats)
+		wg    sync.WaitGroup
+	)
+	bench, cancel := context.WithTimeout(context.Background(), duration)
+	defer cancel()
+
+	start := time.Now()
+	for i := 0; i < concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for bench.Err() == nil {
+				method := mix.pick()
+				params := benchParams(method, uint64(head), span)
+
+				var result json.RawMessage
+				callStart := time.Now()
+				err := client.CallContext(bench, &result, method, params...)
+				elapsed := time.Since(callStart)
+
+				if bench.Err() != nil {
+					return // Calls cut short by the end of the benchmark don't count
+				}
+				lock.Lock()
+				s := stats[method]
+				if s == nil {
+					s = new(benchStats)
+					stats[method] = s
+				}
+				if err != nil {
+					s.errors++
+				} else {
+					s.latencies = append(s.latencies, elapsed)
+				}
+				lock.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+	elapsed := time.Since(start)
+
+	// Report the results of every method
+	methods := make([]string, 0, len(stats))
+	for method := range stats {
+		methods = append(methods, method)
+	}
+	sort.Strings(methods)
+
+	fmt.Printf("\n%-40s %8s %8s %10s %10s %10s %10s %10s\n", "METHOD", "CALLS", "ERRORS", "REQ/S", "P50", "P90", "P99", "MAX")
+	var calls, errs int
+	for _, method := range methods {
+		s := stats[method]
+		sort.Slice(s.latencies, func(i, j int) bool { return s.latencies[i] < s.latencies[j] })
+
+		n := len(s.latencies) + s.errors
+		calls += n
+		errs += s.errors
+		fmt.Printf("%-40s %8d %8d %10.1f %10v %10v %10v %10v\n", method, n, s.errors, float64(n)/elapsed.Seconds(),
+			percentile(s.latencies, 50), percentile(s.latencies, 90), percentile(s.latencies, 99), percentile(s.latencies, 100))
+	}
+	fmt.Printf("\nTotal: %d calls, %d errors, %.1f req/s\n", calls, errs, float64(calls)/elapsed.Seconds())
+	return nil
+}
+
+// percentile returns the given percentile of the sorted latencies.
+func percentile(sorted []time.Duration, p int) time.Duration {
+	if len(sorted) == 0 {
+		return 0
+	}
+	i := (len(sorted)*p + 99) / 100
+	if i > 0 {
+		i--
+	}
+	return sorted[i].Round(time.Microsecond)
+}
@@ -60,6 +60,7 @@ var (
 				Flags: []cli.Flag{
 					utils.DataDirFlag,
 					utils.AncientFlag,
+					utils.ChainDataDirFlag,
 					utils.GardenFlag,
 					utils.CacheTrieJournalFlag,
 					utils.BloomFilterSizeFlag,
@@ -88,6 +89,7 @@ the trie clean cache with default directory will be deleted.
 				Flags: []cli.Flag{
 					utils.DataDirFlag,
 					utils.AncientFlag,
+					utils.ChainDataDirFlag,
 					utils.GardenFlag,
 				},
 				Description: `
@@ -106,6 +108,7 @@ In other words, this command does the snapshot to trie conversion.
 				Flags: []cli.Flag{
 					utils.DataDirFlag,
 					utils.AncientFlag,
+					utils.ChainDataDirFlag,
 					utils.GardenFlag,
 				},
 				Description: `
@@ -126,6 +129,7 @@ It's also usable without snapshot enabled.
 				Flags: []cli.Flag{
 					utils.DataDirFlag,
 					utils.AncientFlag,
+					utils.ChainDataDirFlag,
 					utils.GardenFlag,
 				},
 				Description: `
@@ -147,6 +151,7 @@ It's also usable without snapshot enabled.
 				Flags: []cli.Flag{
 					utils.DataDirFlag,
 					utils.AncientFlag,
+					utils.ChainDataDirFlag,
 					utils.GardenFlag,
 					utils.ExcludeCodeFlag,
 					utils.ExcludeStorageFlag,
@@ -36,7 +36,10 @@ var AppHelpFlagGroups = []flags.FlagGroup{
 			configFileFlag,
 			utils.DataDirFlag,
 			utils.AncientFlag,
+			utils.ChainDataDirFlag,
+			utils.NodeDatabaseDirFlag,
 			utils.MinFreeDiskSpaceFlag,
+			utils.ShutdownTimeoutFlag,
 			utils.KeyStoreDirFlag,
 			utils.USBFlag,
 			utils.NetworkIdFlag,
@@ -47,6 +50,9 @@ var AppHelpFlagGroups = []flags.FlagGroup{
 			utils.LocalFlag,
 			utils.GenesisNonceFlag,
 			utils.SyncModeFlag,
+			utils.SyncTargetFlag,
+			utils.SyncTipTimeoutFlag,
+			utils.SyncRequestTimeoutFlag,
 			utils.ExitWhenSyncedFlag,
 			utils.TxLookupLimitFlag,
 			utils.QuaiStatsURLFlag,
@@ -91,6 +97,9 @@ var AppHelpFlagGroups = []flags.FlagGroup{
 			utils.CacheSnapshotFlag,
 			utils.CacheNoPrefetchFlag,
 			utils.CachePreimagesFlag,
+			utils.CacheCanonicalFlag,
+			utils.CacheMissingBlocksFlag,
+			utils.CacheMissingBlockTTLFlag,
 		},
 	},
 	{
@@ -118,6 +127,7 @@ var AppHelpFlagGroups = []flags.FlagGroup{
 			utils.WSApiFlag,
 			utils.WSPathPrefixFlag,
 			utils.WSAllowedOriginsFlag,
+			utils.RPCAPIKeysFlag,
 			utils.RPCGlobalGasCapFlag,
 			utils.RPCGlobalTxFeeCapFlag,
 			utils.JSpathFlag,
@@ -131,10 +141,17 @@ var AppHelpFlagGroups = []flags.FlagGroup{
 		Name: "NETWORKING",
 		Flags: []cli.Flag{
 			utils.BootnodesFlag,
+			utils.BootnodesFallbackFlag,
+			utils.BootnodesMinLiveFlag,
+			utils.ControlPeersFlag,
 			utils.DNSDiscoveryFlag,
 			utils.ListenPortFlag,
 			utils.MaxPeersFlag,
 			utils.MaxPendingPeersFlag,
+			utils.DialTimeoutFlag,
+			utils.HandshakeTimeoutFlag,
+			utils.WriteTimeoutFlag,
+			utils.ReadTimeoutFlag,
 			utils.NATFlag,
 			utils.NoDiscoverFlag,
 			utils.DiscoveryV5Flag,
@@ -176,6 +193,10 @@ var AppHelpFlagGroups = []flags.FlagGroup{
 		Flags: append([]cli.Flag{
 			utils.FakePoWFlag,
 			utils.NoCompactionFlag,
+			utils.RequestLogSampleFlag,
+			utils.BlockProvenanceFlag,
+			utils.ChainCheckFlag,
+			utils.FirstSeenFlag,
 		}, debug.Flags...),
 	},
 	{
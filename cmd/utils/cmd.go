@@ -46,6 +46,14 @@ const (
 	importBatchSize = 2500
 )
 
+// Exit codes of the node process, letting supervisors tell a clean stop apart
+// from a failed or hung shutdown. A shutdown aborted by repeated interrupts
+// panics, exiting with the status 2 of the Go runtime.
+const (
+	ExitShutdownFailed  = 3 // A subsystem returned an error while stopping
+	ExitShutdownTimeout = 4 // The shutdown didn't complete within the timeout
+)
+
 // Fatalf formats a message to standard error and exits the program.
 // The message is also printed to standard output if standard error
 // is redirected to a different file.
@@ -85,20 +93,49 @@ func StartNode(ctx *cli.Context, stack *node.Node) {
 			go monitorFreeDiskSpace(sigc, stack.InstanceDir(), uint64(minFreeDiskSpace)*1024*1024)
 		}
 
-		<-sigc
-		log.Info("Got interrupt, shutting down...")
-		go stack.Close()
+		// Bound the shutdown whether it was requested by a signal or from within
+		// the node (e.g. --exitwhensynced)
+		select {
+		case <-sigc:
+			log.Info("Got interrupt, shutting down...")
+			go stack.Close()
+		case <-stack.Stopping():
+		}
+
+		var timeout <-chan time.Time
+		if limit := ctx.GlobalDuration(ShutdownTimeoutFlag.Name); limit > 0 {
+			timeout = time.After(limit)
+		}
 		for i := 10; i > 0; i-- {
-			<-sigc
-			if i > 1 {
-				log.Warn("Already shutting down, interrupt more to panic.", "times", i-1)
+			select {
+			case <-sigc:
+				if i > 1 {
+					log.Warn("Already shutting down, interrupt more to panic.", "times", i-1)
+				}
+			case <-timeout:
+				log.Error("Shutdown timed out", "report", stack.ShutdownReport())
+				debug.Exit() // ensure trace and CPU profile data is flushed.
+				os.Exit(ExitShutdownTimeout)
 			}
 		}
+		log.Error("Shutdown aborted", "report", stack.ShutdownReport())
 		debug.Exit() // ensure trace and CPU profile data is flushed.
 		debug.LoudPanic("boom")
 	}()
 }
 
+// ShutdownExitCode logs the outcome of stopping the node, and maps it to the
+// exit code of the process: zero if all subsystems stopped cleanly.
+func ShutdownExitCode(stack *node.Node) int {
+	report := stack.ShutdownReport()
+	if failed := report.Failed(); len(failed) > 0 {
+		log.Error("Shutdown completed with errors", "failed", len(failed), "report", report)
+		return ExitShutdownFailed
+	}
+	log.Info("Shutdown completed", "elapsed", time.Since(report.Started))
+	return 0
+}
+
 func monitorFreeDiskSpace(sigc chan os.Signal, path string, freeDiskSpaceCritical uint64) {
 	for {
 		freeSpace, err := getFreeDiskSpace(path)
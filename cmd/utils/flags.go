@@ -111,10 +111,23 @@ var (
 		Name:  "datadir.ancient",
 		Usage: "Data directory for ancient chain segments (default = inside chaindata)",
 	}
+	ChainDataDirFlag = DirectoryFlag{
+		Name:  "datadir.chaindata",
+		Usage: "Data directory for the chain database (default = inside the datadir)",
+	}
+	NodeDatabaseDirFlag = DirectoryFlag{
+		Name:  "datadir.nodes",
+		Usage: "Data directory for the discovery node database (default = inside the datadir)",
+	}
 	MinFreeDiskSpaceFlag = DirectoryFlag{
 		Name:  "datadir.minfreedisk",
 		Usage: "Minimum free disk space in MB, once reached triggers auto shut down (default = --cache.gc converted to MB, 0 = disabled)",
 	}
+	ShutdownTimeoutFlag = cli.DurationFlag{
+		Name:  "shutdown.timeout",
+		Usage: "Maximum time allowed for a graceful shutdown before exiting with a timeout status (0 = no limit)",
+		Value: 2 * time.Minute,
+	}
 	DBEngineFlag = &cli.StringFlag{
 		Name:  "db.engine",
 		Usage: "Backing database implementation to use ('leveldb' or 'pebble')",
@@ -218,6 +231,20 @@ var (
 		Usage: `Blockchain sync mode ("fast", "full", "snap" or "light")`,
 		Value: &defaultSyncMode,
 	}
+	SyncTargetFlag = cli.Uint64Flag{
+		Name:  "sync.target",
+		Usage: "Block number to sync up to and pause at, until resumed with admin_resumeSync (0 = disabled)",
+	}
+	SyncTipTimeoutFlag = cli.DurationFlag{
+		Name:  "sync.tiptimeout",
+		Usage: "Time allowed for a peer to return a block announced at the head of the chain",
+		Value: ethconfig.Defaults.TipFetchTimeout,
+	}
+	SyncRequestTimeoutFlag = cli.DurationFlag{
+		Name:  "sync.requesttimeout",
+		Usage: "Maximum time allowed for a peer to answer a request for historical data while syncing",
+		Value: ethconfig.Defaults.SyncRequestTimeout,
+	}
 	SnapshotFlag = cli.BoolTFlag{
 		Name:  "snapshot",
 		Usage: `Enables snapshot-database mode (default = enable)`,
@@ -338,6 +365,21 @@ var (
 		Name:  "cache.preimages",
 		Usage: "Enable recording the SHA3/keccak preimages of trie keys",
 	}
+	CacheCanonicalFlag = cli.Uint64Flag{
+		Name:  "cache.canonical",
+		Usage: "Number of recent canonical block hashes to keep indexed in memory (0 = disabled)",
+		Value: ethconfig.Defaults.CanonicalIndexCache,
+	}
+	CacheMissingBlocksFlag = cli.IntFlag{
+		Name:  "cache.missingblocks",
+		Usage: "Number of recently requested missing blocks to remember, to avoid re-requesting them",
+		Value: ethconfig.Defaults.MissingBlockCache,
+	}
+	CacheMissingBlockTTLFlag = cli.DurationFlag{
+		Name:  "cache.missingblockttl",
+		Usage: "Time during which a missing block is not requested again after a failed lookup",
+		Value: ethconfig.Defaults.MissingBlockTTL,
+	}
 	// Consensus settings
 	ConsensusEngineFlag = cli.StringFlag{
 		Name:  "consensus.engine",
@@ -384,6 +426,10 @@ var (
 		Usage: "Sets a cap on gas that can be used in eth_call/estimateGas (0=infinite)",
 		Value: ethconfig.Defaults.RPCGasCap,
 	}
+	RPCAPIKeysFlag = cli.StringFlag{
+		Name:  "rpc.apikeys",
+		Usage: "JSON file of API keys (with rate limits and method allowlists) required on the HTTP and WS RPC endpoints. Keys with limits are HTTP only; dom and sub urls must carry an unlimited key as ?apikey=",
+	}
 	RPCGlobalTxFeeCapFlag = cli.Float64Flag{
 		Name:  "rpc.txfeecap",
 		Usage: "Sets a cap on transaction fee (in ether) that can be sent via the RPC APIs (0 = no cap)",
@@ -490,6 +536,26 @@ var (
 		Usage: "Maximum number of pending connection attempts (defaults used if set to 0)",
 		Value: node.DefaultConfig.P2P.MaxPendingPeers,
 	}
+	DialTimeoutFlag = cli.DurationFlag{
+		Name:  "p2p.dialtimeout",
+		Usage: "Timeout for establishing a TCP connection to a peer",
+		Value: p2p.DefaultDialTimeout,
+	}
+	HandshakeTimeoutFlag = cli.DurationFlag{
+		Name:  "p2p.handshaketimeout",
+		Usage: "Timeout for completing the encryption handshake with a peer",
+		Value: p2p.DefaultHandshakeTimeout,
+	}
+	WriteTimeoutFlag = cli.DurationFlag{
+		Name:  "p2p.writetimeout",
+		Usage: "Timeout for writing a single message to a peer",
+		Value: p2p.DefaultWriteTimeout,
+	}
+	ReadTimeoutFlag = cli.DurationFlag{
+		Name:  "p2p.readtimeout",
+		Usage: "Timeout for reading the next message from a peer, i.e. the longest a connection may stay idle (must exceed the 15s ping interval)",
+		Value: p2p.DefaultReadTimeout,
+	}
 	ListenPortFlag = cli.IntFlag{
 		Name:  "port",
 		Usage: "Network listening port",
@@ -500,6 +566,18 @@ var (
 		Usage: "Comma separated enode URLs for P2P discovery bootstrap",
 		Value: "",
 	}
+	BootnodesFallbackFlag = cli.StringFlag{
+		Name:  "bootnodes.fallback",
+		Usage: "Comma separated enode URLs to bootstrap from if the bootstrap nodes become unreachable",
+	}
+	BootnodesMinLiveFlag = cli.IntFlag{
+		Name:  "bootnodes.minlive",
+		Usage: "Minimum number of reachable bootstrap nodes before failing over to the fallback ones (0 = no liveness checks)",
+	}
+	ControlPeersFlag = cli.StringFlag{
+		Name:  "control.peers",
+		Usage: "Comma separated enode URLs or node IDs allowed to exchange control messages with the node",
+	}
 	NodeKeyFileFlag = cli.StringFlag{
 		Name:  "nodekey",
 		Usage: "P2P node key file",
@@ -619,6 +697,23 @@ var (
 		Usage: "Write log messages to stdout",
 	}
 
+	BlockProvenanceFlag = cli.BoolFlag{
+		Name:  "provenance",
+		Usage: "Record the propagation path of recent blocks (served by debug_getBlockProvenance)",
+	}
+	ChainCheckFlag = cli.BoolFlag{
+		Name:  "chaincheck",
+		Usage: "Periodically check the consistency of the recent chain data, repairing or re-fetching inconsistent entries",
+	}
+	FirstSeenFlag = cli.BoolFlag{
+		Name:  "firstseen",
+		Usage: "Persist the first time every peer sent or announced each block and transaction (served by debug_getFirstSeen and admin_exportFirstSeen)",
+	}
+	RequestLogSampleFlag = cli.Uint64Flag{
+		Name:  "log.requests",
+		Usage: "Log one in every N inbound and outbound protocol requests (0 = disabled)",
+	}
+
 	// Tags are part of every measurement sent to InfluxDB. Queries on tags are faster in InfluxDB.
 	// For example `host` tag could be used so that we can group all nodes and average a measurement
 	// across all of them, but also so that we can select a specific node and inspect its measurements.
@@ -794,6 +889,26 @@ func setBootstrapNodesV5(ctx *cli.Context, cfg *p2p.Config) {
 	}
 }
 
+// setFallbackBootstrapNodes configures the liveness checks of the bootstrap nodes
+// and the nodes to fail over to from the command line flags.
+func setFallbackBootstrapNodes(ctx *cli.Context, cfg *p2p.Config) {
+	if ctx.GlobalIsSet(BootnodesMinLiveFlag.Name) {
+		cfg.MinLiveBootnodes = ctx.GlobalInt(BootnodesMinLiveFlag.Name)
+	}
+	if !ctx.GlobalIsSet(BootnodesFallbackFlag.Name) {
+		return
+	}
+	urls := SplitAndTrim(ctx.GlobalString(BootnodesFallbackFlag.Name))
+	cfg.FallbackBootstrapNodes = make([]*enode.Node, 0, len(urls))
+	for _, url := range urls {
+		node, err := enode.Parse(enode.ValidSchemes, url)
+		if err != nil {
+			Fatalf("Fallback bootstrap URL invalid: %v", err)
+		}
+		cfg.FallbackBootstrapNodes = append(cfg.FallbackBootstrapNodes, node)
+	}
+}
+
 // setListenAddress creates a TCP listening address string from set command
 // line flags.
 func setListenAddress(ctx *cli.Context, cfg *p2p.Config) {
@@ -1061,6 +1176,7 @@ func SetP2PConfig(ctx *cli.Context, cfg *p2p.Config) {
 	setListenAddress(ctx, cfg)
 	setBootstrapNodes(ctx, cfg)
 	setBootstrapNodesV5(ctx, cfg)
+	setFallbackBootstrapNodes(ctx, cfg)
 
 	if ctx.GlobalIsSet(MaxPeersFlag.Name) {
 		cfg.MaxPeers = ctx.GlobalInt(MaxPeersFlag.Name)
@@ -1075,6 +1191,21 @@ func SetP2PConfig(ctx *cli.Context, cfg *p2p.Config) {
 	if ctx.GlobalIsSet(NoDiscoverFlag.Name) {
 		cfg.NoDiscovery = true
 	}
+	if ctx.GlobalIsSet(DialTimeoutFlag.Name) {
+		cfg.DialTimeout = ctx.GlobalDuration(DialTimeoutFlag.Name)
+	}
+	if ctx.GlobalIsSet(HandshakeTimeoutFlag.Name) {
+		cfg.HandshakeTimeout = ctx.GlobalDuration(HandshakeTimeoutFlag.Name)
+	}
+	if ctx.GlobalIsSet(WriteTimeoutFlag.Name) {
+		cfg.WriteTimeout = ctx.GlobalDuration(WriteTimeoutFlag.Name)
+	}
+	if ctx.GlobalIsSet(ReadTimeoutFlag.Name) {
+		cfg.ReadTimeout = ctx.GlobalDuration(ReadTimeoutFlag.Name)
+		if cfg.ReadTimeout <= p2p.MinReadTimeout {
+			Fatalf("Option %q must be longer than the %v ping interval, or idle peers are disconnected", ReadTimeoutFlag.Name, p2p.MinReadTimeout)
+		}
+	}
 
 	// if we're running a light client or server, force enable the v5 peer discovery
 	// unless it is explicitly disabled with --nodiscover note that explicitly specifying
@@ -1109,6 +1240,10 @@ func SetNodeConfig(ctx *cli.Context, cfg *node.Config) {
 	setNodeUserIdent(ctx, cfg)
 	setDataDir(ctx, cfg)
 
+	if ctx.GlobalIsSet(RPCAPIKeysFlag.Name) {
+		cfg.RPCAPIKeysFile = ctx.GlobalString(RPCAPIKeysFlag.Name)
+	}
+
 	if ctx.GlobalIsSet(ExternalSignerFlag.Name) {
 		cfg.ExternalSigner = ctx.GlobalString(ExternalSignerFlag.Name)
 	}
@@ -1116,6 +1251,12 @@ func SetNodeConfig(ctx *cli.Context, cfg *node.Config) {
 	if ctx.GlobalIsSet(KeyStoreDirFlag.Name) {
 		cfg.KeyStoreDir = ctx.GlobalString(KeyStoreDirFlag.Name)
 	}
+	if ctx.GlobalIsSet(ChainDataDirFlag.Name) {
+		cfg.ChainDataDir = ctx.GlobalString(ChainDataDirFlag.Name)
+	}
+	if ctx.GlobalIsSet(NodeDatabaseDirFlag.Name) {
+		cfg.NodeDatabaseDir = ctx.GlobalString(NodeDatabaseDirFlag.Name)
+	}
 	if ctx.GlobalIsSet(DeveloperFlag.Name) {
 		cfg.UseLightweightKDF = true
 	}
@@ -1442,6 +1583,15 @@ func SetEthConfig(ctx *cli.Context, stack *node.Node, cfg *ethconfig.Config) {
 	if ctx.GlobalIsSet(SyncModeFlag.Name) {
 		cfg.SyncMode = *GlobalTextMarshaler(ctx, SyncModeFlag.Name).(*downloader.SyncMode)
 	}
+	if ctx.GlobalIsSet(SyncTargetFlag.Name) {
+		cfg.SyncTarget = ctx.GlobalUint64(SyncTargetFlag.Name)
+	}
+	if ctx.GlobalIsSet(SyncTipTimeoutFlag.Name) {
+		cfg.TipFetchTimeout = ctx.GlobalDuration(SyncTipTimeoutFlag.Name)
+	}
+	if ctx.GlobalIsSet(SyncRequestTimeoutFlag.Name) {
+		cfg.SyncRequestTimeout = ctx.GlobalDuration(SyncRequestTimeoutFlag.Name)
+	}
 	if ctx.GlobalIsSet(NetworkIdFlag.Name) {
 		cfg.NetworkId = ctx.GlobalUint64(NetworkIdFlag.Name)
 	}
@@ -1465,6 +1615,21 @@ func SetEthConfig(ctx *cli.Context, stack *node.Node, cfg *ethconfig.Config) {
 	if ctx.GlobalIsSet(TxLookupLimitFlag.Name) {
 		cfg.TxLookupLimit = ctx.GlobalUint64(TxLookupLimitFlag.Name)
 	}
+	if ctx.GlobalIsSet(RequestLogSampleFlag.Name) {
+		cfg.RequestLogSample = ctx.GlobalUint64(RequestLogSampleFlag.Name)
+	}
+	if ctx.GlobalIsSet(BlockProvenanceFlag.Name) {
+		cfg.BlockProvenance = ctx.GlobalBool(BlockProvenanceFlag.Name)
+	}
+	if ctx.GlobalIsSet(ChainCheckFlag.Name) {
+		cfg.ChainCheck = ctx.GlobalBool(ChainCheckFlag.Name)
+	}
+	if ctx.GlobalIsSet(FirstSeenFlag.Name) {
+		cfg.FirstSeen = ctx.GlobalBool(FirstSeenFlag.Name)
+	}
+	if ctx.GlobalIsSet(ControlPeersFlag.Name) {
+		cfg.ControlPeers = SplitAndTrim(ctx.GlobalString(ControlPeersFlag.Name))
+	}
 	if ctx.GlobalIsSet(CacheFlag.Name) || ctx.GlobalIsSet(CacheTrieFlag.Name) {
 		cfg.TrieCleanCache = ctx.GlobalInt(CacheFlag.Name) * ctx.GlobalInt(CacheTrieFlag.Name) / 100
 	}
@@ -1484,6 +1649,15 @@ func SetEthConfig(ctx *cli.Context, stack *node.Node, cfg *ethconfig.Config) {
 		cfg.TrieCleanCache += cfg.SnapshotCache
 		cfg.SnapshotCache = 0 // Disabled
 	}
+	if ctx.GlobalIsSet(CacheCanonicalFlag.Name) {
+		cfg.CanonicalIndexCache = ctx.GlobalUint64(CacheCanonicalFlag.Name)
+	}
+	if ctx.GlobalIsSet(CacheMissingBlocksFlag.Name) {
+		cfg.MissingBlockCache = ctx.GlobalInt(CacheMissingBlocksFlag.Name)
+	}
+	if ctx.GlobalIsSet(CacheMissingBlockTTLFlag.Name) {
+		cfg.MissingBlockTTL = ctx.GlobalDuration(CacheMissingBlockTTLFlag.Name)
+	}
 	if ctx.GlobalIsSet(DocRootFlag.Name) {
 		cfg.DocRoot = ctx.GlobalString(DocRootFlag.Name)
 	}
@@ -1681,7 +1855,7 @@ func MakeChainDatabase(ctx *cli.Context, stack *node.Node, readonly bool) ethdb.
 		err     error
 		chainDb ethdb.Database
 	)
-	name := "chaindata"
+	name := stack.Config().ChainDB()
 	chainDb, err = stack.OpenDatabaseWithFreezer(name, cache, handles, ctx.GlobalString(AncientFlag.Name), "", readonly)
 	if err != nil {
 		Fatalf("Could not open database: %v", err)
@@ -1735,6 +1909,7 @@ func MakeChain(ctx *cli.Context, stack *node.Node) (*core.Core, ethdb.Database)
 		TrieTimeLimit:       ethconfig.Defaults.TrieTimeout,
 		SnapshotLimit:       ethconfig.Defaults.SnapshotCache,
 		Preimages:           ctx.GlobalBool(CachePreimagesFlag.Name),
+		CanonicalIndexLimit: ctx.GlobalUint64(CacheCanonicalFlag.Name),
 	}
 	if !ctx.GlobalBool(SnapshotFlag.Name) {
 		cache.SnapshotLimit = 0 // Disabled
@@ -78,7 +78,7 @@ func calcExpireTime(ttl int64) int64 {
 
 // removeExpired removes any expired entries from the cache
 func (tc *TimedCache) removeExpired() {
-	for k := range tc.cache.Keys() {
+	for _, k := range tc.cache.Keys() {
 		if val, ok := tc.cache.Peek(k); ok {
 			if v := val.(timedEntry); v.expired() {
 				tc.cache.Remove(k)
@@ -58,9 +58,13 @@ func NewBodyDb(db ethdb.Database, engine consensus.Engine, hc *HeaderChain, chai
 	// slices that are not being processed by the node. This helps lower the RAM
 	// requirement on the slice nodes
 	if bc.ProcessingState() {
-		blockCache, _ := lru.New(blockCacheLimit)
-		bodyCache, _ := lru.New(bodyCacheLimit)
-		bodyRLPCache, _ := lru.New(bodyCacheLimit)
+		var sizes CacheConfig
+		if cacheConfig != nil {
+			sizes = *cacheConfig
+		}
+		blockCache, _ := lru.New(cacheLimit(sizes.BlockCacheLimit, blockCacheLimit))
+		bodyCache, _ := lru.New(cacheLimit(sizes.BodyCacheLimit, bodyCacheLimit))
+		bodyRLPCache, _ := lru.New(cacheLimit(sizes.BodyCacheLimit, bodyCacheLimit))
 		bc.blockCache = blockCache
 		bc.bodyCache = bodyCache
 		bc.bodyRLPCache = bodyRLPCache
@@ -0,0 +1,94 @@
+package core
+
+import (
+	"sync"
+
+	"github.com/dominant-strategies/go-quai/common"
+	"github.com/dominant-strategies/go-quai/core/rawdb"
+	"github.com/dominant-strategies/go-quai/ethdb"
+	"github.com/dominant-strategies/go-quai/log"
+)
+
+// canonicalIndex is an in-memory copy of the canonical number->hash mapping of
+// the most recent blocks, kept in sync with the database on every head change,
+// so that lookups of recent heights never touch disk. A nil index is valid and
+// holds nothing.
+type canonicalIndex struct {
+	size   uint64                 // Number of blocks below the head to index
+	head   uint64                 // Highest indexed block number
+	hashes map[uint64]common.Hash // Block number -> canonical hash
+	lock   sync.RWMutex
+}
+
+// newCanonicalIndex creates an index of the given number of recent blocks, and
+// warms it up with the canonical hashes below the given head. A size of zero
+// disables the index.
+func newCanonicalIndex(db ethdb.Reader, size uint64, head uint64) *canonicalIndex {
+	if size == 0 {
+		return nil
+	}
+	idx := &canonicalIndex{
+		size:   size,
+		head:   head,
+		hashes: make(map[uint64]common.Hash, size),
+	}
+	for i := uint64(0); i < size && i <= head; i++ {
+		hash := rawdb.ReadCanonicalHash(db, head-i)
+		if hash == (common.Hash{}) {
+			break
+		}
+		idx.hashes[head-i] = hash
+	}
+	log.Info("Warmed up canonical hash index", "head", head, "blocks", len(idx.hashes))
+	return idx
+}
+
+// get retrieves the canonical hash at the given height, if it is indexed.
+func (idx *canonicalIndex) get(number uint64) (common.Hash, bool) {
+	if idx == nil {
+		return common.Hash{}, false
+	}
+	idx.lock.RLock()
+	defer idx.lock.RUnlock()
+
+	hash, ok := idx.hashes[number]
+	return hash, ok
+}
+
+// set records the canonical hash at the given height, evicting the heights that
+// fall out of the index as the head advances.
+func (idx *canonicalIndex) set(number uint64, hash common.Hash) {
+	if idx == nil {
+		return
+	}
+	idx.lock.Lock()
+	defer idx.lock.Unlock()
+
+	if number+idx.size <= idx.head {
+		return // Too old to be indexed
+	}
+	if number > idx.head {
+		if number-idx.head >= idx.size {
+			idx.hashes = make(map[uint64]common.Hash, idx.size)
+		} else {
+			for n := idx.head + 1; n <= number; n++ {
+				if n >= idx.size {
+					delete(idx.hashes, n-idx.size)
+				}
+			}
+		}
+		idx.head = number
+	}
+	idx.hashes[number] = hash
+}
+
+// delete drops the canonical hash at the given height.
+func (idx *canonicalIndex) delete(number uint64) {
+	if idx == nil {
+		return
+	}
+	idx.lock.Lock()
+	defer idx.lock.Unlock()
+
+	delete(idx.hashes, number)
+}
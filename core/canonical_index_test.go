@@ -0,0 +1,65 @@
+package core
+
+import (
+	"testing"
+
+	"github.com/dominant-strategies/go-quai/common"
+	"github.com/dominant-strategies/go-quai/core/rawdb"
+)
+
+// Tests that the canonical hashes dropped when rolling the chain back are gone
+// from the in-memory index too, and not only from the database.
+func TestCanonicalIndexRollback(t *testing.T) {
+	db := rawdb.NewMemoryDatabase()
+	for i := uint64(0); i <= 10; i++ {
+		rawdb.WriteCanonicalHash(db, common.BytesToHash([]byte{byte(i + 1)}), i)
+	}
+	hc := &HeaderChain{headerDb: db, canonical: newCanonicalIndex(db, 8, 10)}
+
+	// Roll back the blocks above height 5
+	for i := uint64(10); i > 5; i-- {
+		hc.deleteCanonicalHash(i)
+	}
+	for i := uint64(0); i <= 10; i++ {
+		want := common.BytesToHash([]byte{byte(i + 1)})
+		if i > 5 {
+			want = common.Hash{}
+		}
+		if have := hc.GetCanonicalHash(i); have != want {
+			t.Errorf("height %d: canonical hash mismatch: have %x, want %x", i, have, want)
+		}
+	}
+	// Extend the chain again over the rolled back heights
+	for i := uint64(6); i <= 12; i++ {
+		hash := common.BytesToHash([]byte{byte(i + 100)})
+		rawdb.WriteCanonicalHash(db, hash, i)
+		hc.canonical.set(i, hash)
+	}
+	for i := uint64(6); i <= 12; i++ {
+		if have, want := hc.GetCanonicalHash(i), common.BytesToHash([]byte{byte(i + 100)}); have != want {
+			t.Errorf("height %d: canonical hash mismatch after re-extension: have %x, want %x", i, have, want)
+		}
+	}
+}
+
+// Tests that the index only holds the most recent heights as the head advances.
+func TestCanonicalIndexEviction(t *testing.T) {
+	idx := newCanonicalIndex(rawdb.NewMemoryDatabase(), 4, 0)
+	for i := uint64(0); i < 10; i++ {
+		idx.set(i, common.BytesToHash([]byte{byte(i + 1)}))
+	}
+	for i := uint64(0); i < 10; i++ {
+		_, ok := idx.get(i)
+		if want := i >= 6; ok != want {
+			t.Errorf("height %d: indexed mismatch: have %v, want %v", i, ok, want)
+		}
+	}
+	// Setting a height far above the head drops everything below the window
+	idx.set(100, common.BytesToHash([]byte{0xff}))
+	if _, ok := idx.get(9); ok {
+		t.Errorf("height 9 still indexed after jumping to 100")
+	}
+	if hash, ok := idx.get(100); !ok || hash != common.BytesToHash([]byte{0xff}) {
+		t.Errorf("height 100: have %x (%v), want %x", hash, ok, common.BytesToHash([]byte{0xff}))
+	}
+}
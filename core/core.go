@@ -979,6 +979,12 @@ func (c *Core) PendingBlockAndReceipts() (*types.Block, types.Receipts) {
 	return c.sl.miner.PendingBlockAndReceipts()
 }
 
+// SimulateBlock builds a block on top of the given parent with the given
+// transactions, without sealing or broadcasting it.
+func (c *Core) SimulateBlock(parent *types.Block, txs types.Transactions, coinbase common.Address) (*SimulatedBlock, error) {
+	return c.sl.miner.SimulateBlock(parent, txs, coinbase)
+}
+
 func (c *Core) SetEtherbase(addr common.Address) {
 	c.sl.miner.SetEtherbase(addr)
 }
@@ -57,6 +57,8 @@ type HeaderChain struct {
 	headerCache *lru.Cache // Cache for the most recent block headers
 	numberCache *lru.Cache // Cache for the most recent block numbers
 
+	canonical *canonicalIndex // In-memory canonical hashes of the most recent blocks
+
 	fetchPEtxRollup getPendingEtxsRollup
 	fetchPEtx       getPendingEtxs
 
@@ -77,8 +79,12 @@ type HeaderChain struct {
 // NewHeaderChain creates a new HeaderChain structure. ProcInterrupt points
 // to the parent's interrupt semaphore.
 func NewHeaderChain(db ethdb.Database, engine consensus.Engine, pEtxsRollupFetcher getPendingEtxsRollup, pEtxsFetcher getPendingEtxs, chainConfig *params.ChainConfig, cacheConfig *CacheConfig, txLookupLimit *uint64, vmConfig vm.Config, slicesRunning []common.Location) (*HeaderChain, error) {
-	headerCache, _ := lru.New(headerCacheLimit)
-	numberCache, _ := lru.New(numberCacheLimit)
+	var sizes CacheConfig
+	if cacheConfig != nil {
+		sizes = *cacheConfig
+	}
+	headerCache, _ := lru.New(cacheLimit(sizes.HeaderCacheLimit, headerCacheLimit))
+	numberCache, _ := lru.New(cacheLimit(sizes.NumberCacheLimit, numberCacheLimit))
 	nodeCtx := common.NodeLocation.Context()
 
 	hc := &HeaderChain{
@@ -119,6 +125,9 @@ func NewHeaderChain(db ethdb.Database, engine consensus.Engine, pEtxsRollupFetch
 	if err := hc.loadLastState(); err != nil {
 		return nil, err
 	}
+	if cacheConfig != nil {
+		hc.canonical = newCanonicalIndex(db, cacheConfig.CanonicalIndexLimit, hc.CurrentHeader().NumberU64())
+	}
 
 	var err error
 	hc.bc, err = NewBodyDb(db, engine, hc, chainConfig, cacheConfig, txLookupLimit, vmConfig, slicesRunning)
@@ -335,6 +344,7 @@ func (hc *HeaderChain) SetCurrentHeader(head *types.Header) error {
 	// If head is the normal extension of canonical head, we can return by just wiring the canonical hash.
 	if prevHeader.Hash() == head.ParentHash() {
 		rawdb.WriteCanonicalHash(hc.headerDb, head.Hash(), head.NumberU64())
+		hc.canonical.set(head.NumberU64(), head.Hash())
 		return nil
 	}
 
@@ -362,7 +372,7 @@ func (hc *HeaderChain) SetCurrentHeader(head *types.Header) error {
 		if prevHeader.Hash() == commonHeader.Hash() {
 			break
 		}
-		rawdb.DeleteCanonicalHash(hc.headerDb, prevHeader.NumberU64())
+		hc.deleteCanonicalHash(prevHeader.NumberU64())
 		prevHeader = hc.GetHeader(prevHeader.ParentHash(), prevHeader.NumberU64()-1)
 
 		// genesis check to not delete the genesis block
@@ -374,6 +384,7 @@ func (hc *HeaderChain) SetCurrentHeader(head *types.Header) error {
 	// Run through the hash stack to update canonicalHash and forward state processor
 	for i := len(hashStack) - 1; i >= 0; i-- {
 		rawdb.WriteCanonicalHash(hc.headerDb, hashStack[i].Hash(), hashStack[i].NumberU64())
+		hc.canonical.set(hashStack[i].NumberU64(), hashStack[i].Hash())
 	}
 
 	return nil
@@ -732,16 +743,27 @@ func (hc *HeaderChain) HasHeader(hash common.Hash, number uint64) bool {
 // GetHeaderByNumber retrieves a block header from the database by number,
 // caching it (associated with its hash) if found.
 func (hc *HeaderChain) GetHeaderByNumber(number uint64) *types.Header {
-	hash := rawdb.ReadCanonicalHash(hc.headerDb, number)
+	hash := hc.GetCanonicalHash(number)
 	if hash == (common.Hash{}) {
 		return nil
 	}
 	return hc.GetHeader(hash, number)
 }
 
+// deleteCanonicalHash removes the canonical hash at the given height from both
+// the database and the in-memory index.
+func (hc *HeaderChain) deleteCanonicalHash(number uint64) {
+	rawdb.DeleteCanonicalHash(hc.headerDb, number)
+	hc.canonical.delete(number)
+}
+
+// GetCanonicalHash retrieves the canonical hash at the given height, from the
+// in-memory index for recent heights and from the database otherwise.
 func (hc *HeaderChain) GetCanonicalHash(number uint64) common.Hash {
-	hash := rawdb.ReadCanonicalHash(hc.headerDb, number)
-	return hash
+	if hash, ok := hc.canonical.get(number); ok {
+		return hash
+	}
+	return rawdb.ReadCanonicalHash(hc.headerDb, number)
 }
 
 // CurrentHeader retrieves the current head header of the canonical chain. The
@@ -888,7 +910,7 @@ func (hc *HeaderChain) GetBlockOrCandidateByHash(hash common.Hash) *types.Block
 // GetBlockByNumber retrieves a block from the database by number, caching it
 // (associated with its hash) if found.
 func (hc *HeaderChain) GetBlockByNumber(number uint64) *types.Block {
-	hash := rawdb.ReadCanonicalHash(hc.headerDb, number)
+	hash := hc.GetCanonicalHash(number)
 	if hash == (common.Hash{}) {
 		return nil
 	}
@@ -160,6 +160,12 @@ func (miner *Miner) PendingBlockAndReceipts() (*types.Block, types.Receipts) {
 	return miner.worker.pendingBlockAndReceipts()
 }
 
+// SimulateBlock builds a block on top of the given parent with the given
+// transactions, without sealing or broadcasting it.
+func (miner *Miner) SimulateBlock(parent *types.Block, txs types.Transactions, coinbase common.Address) (*SimulatedBlock, error) {
+	return miner.worker.simulateBlock(parent, txs, coinbase)
+}
+
 func (miner *Miner) SetEtherbase(addr common.Address) {
 	miner.coinbase = addr
 	miner.worker.setEtherbase(addr)
@@ -164,6 +164,32 @@ func WriteHeadBlockHash(db ethdb.KeyValueWriter, hash common.Hash) {
 	}
 }
 
+// ReadSyncFrontierHash retrieves the hash of the last block imported by the
+// downloader.
+func ReadSyncFrontierHash(db ethdb.KeyValueReader) common.Hash {
+	data, _ := db.Get(syncFrontierKey)
+	if len(data) == 0 {
+		return common.Hash{}
+	}
+	return common.BytesToHash(data)
+}
+
+// WriteSyncFrontierHash stores the hash of the last block imported by the
+// downloader.
+func WriteSyncFrontierHash(db ethdb.KeyValueWriter, hash common.Hash) {
+	if err := db.Put(syncFrontierKey, hash.Bytes()); err != nil {
+		log.Fatal("Failed to store sync frontier hash", "err", err)
+	}
+}
+
+// DeleteSyncFrontierHash removes the hash of the last block imported by the
+// downloader.
+func DeleteSyncFrontierHash(db ethdb.KeyValueWriter) {
+	if err := db.Delete(syncFrontierKey); err != nil {
+		log.Fatal("Failed to delete sync frontier hash", "err", err)
+	}
+}
+
 // ReadLastPivotNumber retrieves the number of the last pivot block. If the node
 // full synced, the last pivot will always be nil.
 func ReadLastPivotNumber(db ethdb.KeyValueReader) *uint64 {
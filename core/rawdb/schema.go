@@ -45,6 +45,10 @@ var (
 	// phHead tracks the latest known pending headers hash in Blockchain.
 	phHeadKey = []byte("PhHead")
 
+	// syncFrontierKey tracks the last block imported by the downloader, to resume
+	// an interrupted sync across restarts.
+	syncFrontierKey = []byte("SyncFrontier")
+
 	// lastPivotKey tracks the last pivot block used by fast sync (to reenable on sethead).
 	lastPivotKey = []byte("LastPivot")
 
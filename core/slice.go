@@ -1415,7 +1415,7 @@ func (sl *Slice) cleanCacheAndDatabaseTillBlock(hash common.Hash) {
 	header := currentHeader
 	for {
 		rawdb.DeleteBlock(sl.sliceDb, header.Hash(), header.NumberU64())
-		rawdb.DeleteCanonicalHash(sl.sliceDb, header.NumberU64())
+		sl.hc.deleteCanonicalHash(header.NumberU64())
 		rawdb.DeleteHeaderNumber(sl.sliceDb, header.Hash())
 		rawdb.DeleteTermini(sl.sliceDb, header.Hash())
 		rawdb.DeleteEtxSet(sl.sliceDb, header.Hash(), header.NumberU64())
@@ -99,15 +99,31 @@ type CacheConfig struct {
 	TrieTimeLimit       time.Duration // Time limit after which to flush the current in-memory trie to disk
 	SnapshotLimit       int           // Memory allowance (MB) to use for caching snapshot entries in memory
 	Preimages           bool          // Whether to store preimage of trie key to the disk
+	CanonicalIndexLimit uint64        // Number of recent canonical hashes to index in memory
+	HeaderCacheLimit    int           // Number of recent headers to cache in memory (0 = default)
+	NumberCacheLimit    int           // Number of recent hash to number lookups to cache in memory (0 = default)
+	BodyCacheLimit      int           // Number of recent block bodies to cache in memory (0 = default)
+	BlockCacheLimit     int           // Number of recent blocks to cache in memory (0 = default)
+	TxLookupCacheLimit  int           // Number of recent transaction lookups to cache in memory (0 = default)
+}
+
+// cacheLimit returns the configured size of a cache, or the given default if
+// no size is configured.
+func cacheLimit(size int, def int) int {
+	if size > 0 {
+		return size
+	}
+	return def
 }
 
 // defaultCacheConfig are the default caching values if none are specified by the
 // user (also used during testing).
 var defaultCacheConfig = &CacheConfig{
-	TrieCleanLimit: 256,
-	TrieDirtyLimit: 256,
-	TrieTimeLimit:  5 * time.Minute,
-	SnapshotLimit:  256,
+	TrieCleanLimit:      256,
+	TrieDirtyLimit:      256,
+	TrieTimeLimit:       5 * time.Minute,
+	SnapshotLimit:       256,
+	CanonicalIndexLimit: 8192,
 }
 
 // StateProcessor is a basic Processor, which takes care of transitioning
@@ -140,12 +156,11 @@ type StateProcessor struct {
 
 // NewStateProcessor initialises a new StateProcessor.
 func NewStateProcessor(config *params.ChainConfig, hc *HeaderChain, engine consensus.Engine, vmConfig vm.Config, cacheConfig *CacheConfig, txLookupLimit *uint64) *StateProcessor {
-	receiptsCache, _ := lru.New(receiptsCacheLimit)
-	txLookupCache, _ := lru.New(txLookupCacheLimit)
-
 	if cacheConfig == nil {
 		cacheConfig = defaultCacheConfig
 	}
+	receiptsCache, _ := lru.New(receiptsCacheLimit)
+	txLookupCache, _ := lru.New(cacheLimit(cacheConfig.TxLookupCacheLimit, txLookupCacheLimit))
 
 	sp := &StateProcessor{
 		config:        config,
@@ -523,6 +523,55 @@ func (w *worker) GeneratePendingHeader(block *types.Block, fill bool) (*types.He
 	return work.header, nil
 }
 
+// SimulatedBlock is the outcome of building a block on top of a given parent,
+// without sealing, storing or broadcasting it.
+type SimulatedBlock struct {
+	Header   *types.Header         // Header of the block, with the resulting state root
+	Receipts types.Receipts        // Receipts of the included transactions
+	Etxs     types.Transactions    // External transactions emitted by the block
+	Rejected map[common.Hash]error // Transactions which could not be included
+}
+
+// simulateBlock builds a block template on top of the given parent, including
+// the given transactions in order, and returns the outcome. The chain and the
+// pending work of the worker are left untouched.
+func (w *worker) simulateBlock(parent *types.Block, txs types.Transactions, coinbase common.Address) (*SimulatedBlock, error) {
+	if common.NodeLocation.Context() != common.ZONE_CTX || !w.hc.ProcessingState() {
+		return nil, errors.New("blocks can only be simulated on zone chains processing state")
+	}
+	work, err := w.prepareWork(&generateParams{
+		timestamp: uint64(time.Now().Unix()),
+		coinbase:  coinbase,
+	}, parent)
+	if err != nil {
+		return nil, err
+	}
+	work.coinbase = coinbase
+	work.header.SetCoinbase(coinbase)
+	w.adjustGasLimit(nil, work, parent)
+	work.gasPool = new(GasPool).AddGas(work.header.GasLimit())
+
+	rejected := make(map[common.Hash]error)
+	for _, tx := range txs {
+		work.state.Prepare(tx.Hash(), work.tcount)
+		if _, err := w.commitTransaction(work, tx); err != nil {
+			rejected[tx.Hash()] = err
+			continue
+		}
+		work.tcount++
+	}
+	block, err := w.engine.FinalizeAndAssemble(w.hc, work.header, work.state, work.txs, work.unclelist(), work.etxs, work.subManifest, work.receipts)
+	if err != nil {
+		return nil, err
+	}
+	return &SimulatedBlock{
+		Header:   block.Header(),
+		Receipts: work.receipts,
+		Etxs:     work.etxs,
+		Rejected: rejected,
+	}, nil
+}
+
 // printPendingHeaderInfo logs the pending header information
 func (w *worker) printPendingHeaderInfo(work *environment, block *types.Block, start time.Time) {
 	work.uncleMu.RLock()
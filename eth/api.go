@@ -33,6 +33,7 @@ import (
 	"github.com/dominant-strategies/go-quai/core/rawdb"
 	"github.com/dominant-strategies/go-quai/core/state"
 	"github.com/dominant-strategies/go-quai/core/types"
+	"github.com/dominant-strategies/go-quai/eth/protocols/control"
 	"github.com/dominant-strategies/go-quai/internal/quaiapi"
 	"github.com/dominant-strategies/go-quai/rlp"
 	"github.com/dominant-strategies/go-quai/rpc"
@@ -139,6 +140,27 @@ func NewPrivateAdminAPI(eth *Quai) *PrivateAdminAPI {
 	return &PrivateAdminAPI{eth: eth}
 }
 
+// PauseSync stops syncing the chain from peers, while keeping the node and its
+// RPC endpoints running. It returns false if sync was already paused.
+func (api *PrivateAdminAPI) PauseSync() bool {
+	return api.eth.handler.pauseSync()
+}
+
+// ResumeSync restarts syncing the chain from peers after PauseSync or after the
+// sync target was reached, removing the sync target. It returns false if sync
+// was not paused.
+func (api *PrivateAdminAPI) ResumeSync() bool {
+	api.eth.handler.setSyncTarget(0)
+	return api.eth.handler.resumeSync()
+}
+
+// SyncTo syncs the chain up to the given block number and then pauses sync,
+// until resumed with ResumeSync. Sync is resumed if it was paused.
+func (api *PrivateAdminAPI) SyncTo(number hexutil.Uint64) {
+	api.eth.handler.setSyncTarget(uint64(number))
+	api.eth.handler.resumeSync()
+}
+
 // ExportChain exports the current blockchain into a local file,
 // or a range of blocks if first and last are non-nil
 func (api *PrivateAdminAPI) ExportChain(file string, first *uint64, last *uint64) (bool, error) {
@@ -178,6 +200,32 @@ func (api *PrivateAdminAPI) ExportChain(file string, first *uint64, last *uint64
 	return true, nil
 }
 
+// ExportFirstSeen writes the recorded first sightings of blocks and transactions
+// to a CSV file, returning the number of records written. It requires the node
+// to run with --firstseen.
+func (api *PrivateAdminAPI) ExportFirstSeen(file string) (int, error) {
+	if api.eth.handler.firstSeen == nil {
+		return 0, errors.New("first seen recording is disabled")
+	}
+	if _, err := os.Stat(file); err == nil {
+		// File already exists. Allowing overwrite could be a DoS vecotor,
+		// since the 'file' may point to arbitrary paths on the drive
+		return 0, errors.New("location would overwrite an existing file")
+	}
+	out, err := os.OpenFile(file, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, os.ModePerm)
+	if err != nil {
+		return 0, err
+	}
+	defer out.Close()
+
+	var writer io.Writer = out
+	if strings.HasSuffix(file, ".gz") {
+		writer = gzip.NewWriter(writer)
+		defer writer.(*gzip.Writer).Close()
+	}
+	return api.eth.handler.firstSeen.export(writer)
+}
+
 func hasAllBlocks(chain *core.Core, bs []*types.Block) bool {
 	for _, b := range bs {
 		if !chain.HasBlock(b.Hash(), b.NumberU64()) {
@@ -236,6 +284,113 @@ func (api *PrivateAdminAPI) ImportChain(file string) (bool, error) {
 	return true, nil
 }
 
+// SendControlMessage sends a control message to an allowlisted node connected
+// over the control protocol.
+func (api *PrivateAdminAPI) SendControlMessage(node string, topic string, payload hexutil.Bytes) (bool, error) {
+	if api.eth.control == nil {
+		return false, errors.New("control protocol is disabled")
+	}
+	id, err := parseNodeID(node)
+	if err != nil {
+		return false, err
+	}
+	if err := api.eth.control.Send(id, topic, payload); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// ControlMessages returns the most recent control messages received from the
+// allowlisted nodes, oldest first.
+func (api *PrivateAdminAPI) ControlMessages() ([]control.Received, error) {
+	if api.eth.control == nil {
+		return nil, errors.New("control protocol is disabled")
+	}
+	return api.eth.control.Messages(), nil
+}
+
+// ControlPeers returns the allowlisted nodes currently connected over the control
+// protocol.
+func (api *PrivateAdminAPI) ControlPeers() ([]string, error) {
+	if api.eth.control == nil {
+		return nil, errors.New("control protocol is disabled")
+	}
+	return api.eth.control.Peers(), nil
+}
+
+// errInjectUnauthenticated is returned by the injection methods if the admin API
+// is reachable by anyone without an API key.
+var errInjectUnauthenticated = errors.New("injection requires the admin API to be served only on loopback or API key protected endpoints")
+
+// InjectedBlock is the outcome of injecting a single block.
+type InjectedBlock struct {
+	Hash  common.Hash `json:"hash"`
+	Error string      `json:"error,omitempty"`
+}
+
+// InjectBlocks runs the given RLP encoded blocks through the same checks as the
+// blocks broadcast by peers, and inserts those passing them into the chain,
+// returning the outcome of every one. The accepted blocks are relayed to the
+// peers if relay is set.
+func (api *PrivateAdminAPI) InjectBlocks(blobs []hexutil.Bytes, relay bool) ([]InjectedBlock, error) {
+	if !api.eth.injectAllowed() {
+		return nil, errInjectUnauthenticated
+	}
+	blocks := make([]*types.Block, len(blobs))
+	for i, blob := range blobs {
+		block := new(types.Block)
+		if err := rlp.DecodeBytes(blob, block); err != nil {
+			return nil, fmt.Errorf("block %d: failed to parse: %v", i, err)
+		}
+		if err := block.SanityCheck(); err != nil {
+			return nil, fmt.Errorf("block %d: %v", i, err)
+		}
+		blocks[i] = block
+	}
+	results := make([]InjectedBlock, len(blocks))
+	for i, block := range blocks {
+		block.ReceivedAt = time.Now()
+		results[i].Hash = block.Hash()
+		if err := api.eth.handler.blockFetcher.InjectBlock(block, relay); err != nil {
+			results[i].Error = err.Error()
+		}
+	}
+	return results, nil
+}
+
+// InjectedTx is the outcome of injecting a single transaction.
+type InjectedTx struct {
+	Hash  common.Hash `json:"hash"`
+	Error string      `json:"error,omitempty"`
+}
+
+// InjectTransactions adds the given binary encoded transactions to the pool as
+// if they were received from a peer, returning the outcome of every one.
+func (api *PrivateAdminAPI) InjectTransactions(blobs []hexutil.Bytes) ([]InjectedTx, error) {
+	if !api.eth.injectAllowed() {
+		return nil, errInjectUnauthenticated
+	}
+	if common.NodeLocation.Context() != common.ZONE_CTX || !api.eth.core.ProcessingState() {
+		return nil, errors.New("transactions are only handled in zone chains processing state")
+	}
+	txs := make([]*types.Transaction, len(blobs))
+	for i, blob := range blobs {
+		tx := new(types.Transaction)
+		if err := tx.UnmarshalBinary(blob); err != nil {
+			return nil, fmt.Errorf("transaction %d: %v", i, err)
+		}
+		txs[i] = tx
+	}
+	results := make([]InjectedTx, len(txs))
+	for i, err := range api.eth.handler.txpool.AddRemotes(txs) {
+		results[i].Hash = txs[i].Hash()
+		if err != nil {
+			results[i].Error = err.Error()
+		}
+	}
+	return results, nil
+}
+
 // PublicDebugAPI is the collection of Quai full node APIs exposed
 // over the public debugging endpoint.
 type PublicDebugAPI struct {
@@ -293,6 +448,83 @@ func (api *PrivateDebugAPI) Preimage(ctx context.Context, hash common.Hash) (hex
 	return nil, errors.New("unknown preimage")
 }
 
+// GetFirstSeen returns the first time every peer sent or announced the block or
+// transaction with the given hash, ordered by time. It requires the node to run
+// with --firstseen.
+func (api *PrivateDebugAPI) GetFirstSeen(hash common.Hash) ([]FirstSeen, error) {
+	if api.eth.handler.firstSeen == nil {
+		return nil, errors.New("first seen recording is disabled")
+	}
+	return api.eth.handler.firstSeen.get(hash), nil
+}
+
+// GetBlockProvenance returns the peer a recent block was first received from and
+// the peers it was forwarded to. It requires the node to run with --provenance.
+func (api *PrivateDebugAPI) GetBlockProvenance(hash common.Hash) (*BlockProvenance, error) {
+	if api.eth.handler.provenance == nil {
+		return nil, errors.New("block provenance tracking is disabled")
+	}
+	if prov := api.eth.handler.provenance.get(hash); prov != nil {
+		return prov, nil
+	}
+	return nil, errors.New("unknown block")
+}
+
+// DryRunResult is the outcome of a block built by DryRunBlock.
+type DryRunResult struct {
+	StateRoot common.Hash            `json:"stateRoot"`
+	GasUsed   hexutil.Uint64         `json:"gasUsed"`
+	GasLimit  hexutil.Uint64         `json:"gasLimit"`
+	Receipts  types.Receipts         `json:"receipts"`
+	Etxs      types.Transactions     `json:"etxs"`
+	Rejected  map[common.Hash]string `json:"rejected"` // Transactions which could not be included, with the reason
+}
+
+// DryRunBlock builds a block on top of the given parent block, including the
+// given signed transactions in order, and returns the resulting state root, gas
+// usage and emitted ETXs. The block is neither sealed, stored nor broadcast. If
+// no coinbase is given, the etherbase of the node is used.
+func (api *PrivateDebugAPI) DryRunBlock(parentHash common.Hash, txs []hexutil.Bytes, coinbase *common.Address) (*DryRunResult, error) {
+	parent := api.eth.core.GetBlockByHash(parentHash)
+	if parent == nil {
+		return nil, fmt.Errorf("parent block %x not found", parentHash)
+	}
+	decoded := make(types.Transactions, len(txs))
+	for i, blob := range txs {
+		tx := new(types.Transaction)
+		if err := tx.UnmarshalBinary(blob); err != nil {
+			return nil, fmt.Errorf("transaction %d: %v", i, err)
+		}
+		decoded[i] = tx
+	}
+	var author common.Address
+	if coinbase != nil {
+		author = *coinbase
+	} else {
+		etherbase, err := api.eth.Etherbase()
+		if err != nil {
+			return nil, err
+		}
+		author = etherbase
+	}
+	block, err := api.eth.core.SimulateBlock(parent, decoded, author)
+	if err != nil {
+		return nil, err
+	}
+	result := &DryRunResult{
+		StateRoot: block.Header.Root(),
+		GasUsed:   hexutil.Uint64(block.Header.GasUsed()),
+		GasLimit:  hexutil.Uint64(block.Header.GasLimit()),
+		Receipts:  block.Receipts,
+		Etxs:      block.Etxs,
+		Rejected:  make(map[common.Hash]string, len(block.Rejected)),
+	}
+	for hash, err := range block.Rejected {
+		result.Rejected[hash] = err.Error()
+	}
+	return result, nil
+}
+
 // BadBlockArgs represents the entries in the list returned when bad blocks are queried.
 type BadBlockArgs struct {
 	Hash  common.Hash            `json:"hash"`
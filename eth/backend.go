@@ -20,6 +20,7 @@ package eth
 import (
 	"fmt"
 	"math/big"
+	"strings"
 	"sync"
 	"sync/atomic"
 	"time"
@@ -36,6 +37,7 @@ import (
 	"github.com/dominant-strategies/go-quai/eth/ethconfig"
 	"github.com/dominant-strategies/go-quai/eth/filters"
 	"github.com/dominant-strategies/go-quai/eth/gasprice"
+	"github.com/dominant-strategies/go-quai/eth/protocols/control"
 	"github.com/dominant-strategies/go-quai/eth/protocols/eth"
 	"github.com/dominant-strategies/go-quai/ethdb"
 	"github.com/dominant-strategies/go-quai/event"
@@ -60,6 +62,8 @@ type Quai struct {
 	// Handlers
 	core               *core.Core
 	handler            *handler
+	control            *control.Handler // Operator control channel (nil if disabled)
+	injectAllowed      func() bool      // Reports whether the admin API is only served on authenticated endpoints
 	ethDialCandidates  enode.Iterator
 	snapDialCandidates enode.Iterator
 
@@ -110,7 +114,7 @@ func New(stack *node.Node, config *ethconfig.Config) (*Quai, error) {
 	log.Info("Allocated trie memory caches", "clean", common.StorageSize(config.TrieCleanCache)*1024*1024, "dirty", common.StorageSize(config.TrieDirtyCache)*1024*1024)
 
 	// Assemble the Quai object
-	chainDb, err := stack.OpenDatabaseWithFreezer("chaindata", config.DatabaseCache, config.DatabaseHandles, config.DatabaseFreezer, "eth/db/chaindata/", false)
+	chainDb, err := stack.OpenDatabaseWithFreezer(stack.Config().ChainDB(), config.DatabaseCache, config.DatabaseHandles, config.DatabaseFreezer, "eth/db/chaindata/", false)
 	if err != nil {
 		return nil, err
 	}
@@ -176,6 +180,12 @@ func New(stack *node.Node, config *ethconfig.Config) (*Quai, error) {
 			TrieTimeLimit:       config.TrieTimeout,
 			SnapshotLimit:       config.SnapshotCache,
 			Preimages:           config.Preimages,
+			CanonicalIndexLimit: config.CanonicalIndexCache,
+			HeaderCacheLimit:    config.HeaderCache,
+			NumberCacheLimit:    config.NumberCache,
+			BodyCacheLimit:      config.BodyCache,
+			BlockCacheLimit:     config.BlockCache,
+			TxLookupCacheLimit:  config.TxLookupCache,
 		}
 	)
 
@@ -196,6 +206,12 @@ func New(stack *node.Node, config *ethconfig.Config) (*Quai, error) {
 
 	// Permit the downloader to use the trie cache allowance during fast sync
 	cacheLimit := cacheConfig.TrieCleanLimit + cacheConfig.TrieDirtyLimit + cacheConfig.SnapshotLimit
+	var firstSeenDb ethdb.Database
+	if config.FirstSeen {
+		if firstSeenDb, err = stack.OpenDatabase("firstseen", 16, 16, "eth/db/firstseen/", false); err != nil {
+			return nil, err
+		}
+	}
 	if eth.handler, err = newHandler(&handlerConfig{
 		Database:      chainDb,
 		Core:          eth.core,
@@ -206,6 +222,15 @@ func New(stack *node.Node, config *ethconfig.Config) (*Quai, error) {
 		EventMux:      eth.eventMux,
 		Whitelist:     config.Whitelist,
 		SlicesRunning: config.SlicesRunning,
+		Provenance:    config.BlockProvenance,
+		SyncTarget:    config.SyncTarget,
+		ChainCheck:    config.ChainCheck,
+		FirstSeenDB:   firstSeenDb,
+		TipTimeout:    config.TipFetchTimeout,
+		SyncTimeout:   config.SyncRequestTimeout,
+
+		MissingBlockCache: config.MissingBlockCache,
+		MissingBlockTTL:   config.MissingBlockTTL,
 	}); err != nil {
 		return nil, err
 	}
@@ -227,6 +252,21 @@ func New(stack *node.Node, config *ethconfig.Config) (*Quai, error) {
 		return nil, err
 	}
 
+	// Set up the operator control channel if any peers are allowed on it
+	if len(config.ControlPeers) > 0 {
+		ids := make([]enode.ID, len(config.ControlPeers))
+		for i, peer := range config.ControlPeers {
+			if ids[i], err = parseNodeID(peer); err != nil {
+				return nil, fmt.Errorf("invalid control peer %q: %v", peer, err)
+			}
+		}
+		eth.control = control.NewHandler(ids)
+	}
+
+	// Local injection of blocks and transactions is only allowed while no
+	// outsider can reach the admin API
+	eth.injectAllowed = func() bool { return stack.ModuleAuthenticated("admin") }
+
 	// Start the RPC service
 	eth.netRPCService = quaiapi.NewPublicNetAPI(eth.p2pServer, config.NetworkId)
 
@@ -312,6 +352,18 @@ func (s *Quai) APIs() []rpc.API {
 	}...)
 }
 
+// parseNodeID parses a node given either as an enode URL or as a hex node ID.
+func parseNodeID(node string) (enode.ID, error) {
+	if strings.HasPrefix(node, "enode://") {
+		n, err := enode.ParseV4(node)
+		if err != nil {
+			return enode.ID{}, err
+		}
+		return n.ID(), nil
+	}
+	return enode.ParseID(node)
+}
+
 func (s *Quai) Etherbase() (eb common.Address, err error) {
 	s.lock.RLock()
 	etherbase := s.etherbase
@@ -377,6 +429,9 @@ func (s *Quai) BloomIndexer() *core.ChainIndexer   { return s.bloomIndexer }
 // network protocols to start.
 func (s *Quai) Protocols() []p2p.Protocol {
 	protos := eth.MakeProtocols((*ethHandler)(s.handler), s.networkID, s.ethDialCandidates)
+	if s.control != nil {
+		protos = append(protos, s.control.Protocols()...)
+	}
 	return protos
 }
 
@@ -384,6 +439,7 @@ func (s *Quai) Protocols() []p2p.Protocol {
 // Quai protocol implementation.
 func (s *Quai) Start() error {
 	eth.StartENRUpdater(s.core, s.p2pServer.LocalNode())
+	eth.SetRequestLogSampling(s.config.RequestLogSample)
 
 	if s.core.ProcessingState() && common.NodeLocation.Context() == common.ZONE_CTX {
 		// Start the bloom bits servicing goroutines
@@ -0,0 +1,153 @@
+// Copyright 2023 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package eth
+
+import (
+	"time"
+
+	"github.com/dominant-strategies/go-quai/common"
+	"github.com/dominant-strategies/go-quai/core/rawdb"
+	"github.com/dominant-strategies/go-quai/core/types"
+	"github.com/dominant-strategies/go-quai/log"
+	"github.com/dominant-strategies/go-quai/metrics"
+	"github.com/dominant-strategies/go-quai/trie"
+)
+
+const (
+	// c_consistencyCheckInterval is the time between two walks of the recent
+	// chain by the consistency checker
+	c_consistencyCheckInterval = 10 * time.Minute
+
+	// c_consistencyCheckDepth is the number of blocks below the head checked
+	// on every walk
+	c_consistencyCheckDepth = 256
+
+	// c_consistencyCheckDelay is the pause between the checks of two blocks,
+	// keeping the checker from competing with block processing for disk io
+	c_consistencyCheckDelay = 20 * time.Millisecond
+)
+
+var (
+	consistencyCheckedMeter  = metrics.NewRegisteredMeter("eth/consistency/checked", nil)
+	consistencyLinksMeter    = metrics.NewRegisteredMeter("eth/consistency/brokenlinks", nil)
+	consistencyBodiesMeter   = metrics.NewRegisteredMeter("eth/consistency/badbodies", nil)
+	consistencyReceiptsMeter = metrics.NewRegisteredMeter("eth/consistency/badreceipts", nil)
+	consistencyIndexMeter    = metrics.NewRegisteredMeter("eth/consistency/repairedindices", nil)
+)
+
+// consistencyLoop periodically walks the most recent canonical blocks, checking
+// the integrity of the stored chain data.
+func (h *handler) consistencyLoop() {
+	defer h.wg.Done()
+
+	ticker := time.NewTicker(c_consistencyCheckInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			h.checkConsistency()
+		case <-h.quitSync:
+			return
+		}
+	}
+}
+
+// checkConsistency checks the most recent canonical blocks. Missing transaction
+// lookup entries are rewritten, and parent blocks whose header is gone are
+// re-requested from the peers. Other problems, such as broken links or corrupted
+// bodies and receipts of stored headers, can't be repaired through the import
+// path, which skips known headers, so they are only reported.
+func (h *handler) checkConsistency() {
+	head := h.core.CurrentHeader()
+	if head == nil {
+		return
+	}
+	var (
+		nodeCtx  = common.NodeLocation.Context()
+		zone     = nodeCtx == common.ZONE_CTX && h.core.ProcessingState()
+		number   = head.NumberU64()
+		child    = head
+		problems int
+	)
+	for i := 0; i < c_consistencyCheckDepth && number > 0; i++ {
+		select {
+		case <-time.After(c_consistencyCheckDelay):
+		case <-h.quitSync:
+			return
+		}
+		number--
+		consistencyCheckedMeter.Mark(1)
+
+		// The parent of the previous block must be the canonical block at this height
+		hash := h.core.GetCanonicalHash(number)
+		if hash != child.ParentHash() {
+			log.Warn("Inconsistent canonical chain", "number", number, "canonical", hash, "parent", child.ParentHash())
+			consistencyLinksMeter.Mark(1)
+			problems++
+			h.refetchParent(child)
+			return // Nothing below can be trusted to be linked
+		}
+		block := h.core.GetBlock(hash, number)
+		if block == nil {
+			log.Warn("Missing canonical block", "number", number, "hash", hash)
+			consistencyBodiesMeter.Mark(1)
+			problems++
+			h.refetchParent(child)
+			return
+		}
+		child = block.Header()
+
+		if !zone {
+			continue
+		}
+		if root := types.DeriveSha(block.Transactions(), trie.NewStackTrie(nil)); root != child.TxHash() {
+			log.Warn("Corrupted block body", "number", number, "hash", hash, "have", root, "want", child.TxHash())
+			consistencyBodiesMeter.Mark(1)
+			problems++
+			continue
+		}
+		if root := types.DeriveSha(h.core.GetReceiptsByHash(hash), trie.NewStackTrie(nil)); root != child.ReceiptHash() {
+			log.Warn("Corrupted block receipts", "number", number, "hash", hash, "have", root, "want", child.ReceiptHash())
+			consistencyReceiptsMeter.Mark(1)
+			problems++
+		}
+		for _, tx := range block.Transactions() {
+			if entry := rawdb.ReadTxLookupEntry(h.database, tx.Hash()); entry == nil || *entry != number {
+				log.Warn("Repairing transaction index", "number", number, "hash", hash, "tx", tx.Hash())
+				rawdb.WriteTxLookupEntriesByBlock(h.database, block)
+				consistencyIndexMeter.Mark(1)
+				problems++
+				break
+			}
+		}
+	}
+	log.Debug("Checked chain consistency", "head", head.NumberU64(), "depth", head.NumberU64()-number, "problems", problems)
+}
+
+// refetchParent requests the parent of a block from the peers, as if it was
+// reported missing by the core. Only parents without a stored header are
+// requested, since the import path skips the blocks whose header is known.
+func (h *handler) refetchParent(child *types.Header) {
+	if h.core.GetHeaderByHash(child.ParentHash()) != nil {
+		return
+	}
+	select {
+	case h.missingBlockCh <- types.BlockRequest{Hash: child.ParentHash(), Entropy: child.ParentEntropy()}:
+	default:
+	}
+}
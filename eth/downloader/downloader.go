@@ -28,6 +28,7 @@ import (
 	quai "github.com/dominant-strategies/go-quai"
 	"github.com/dominant-strategies/go-quai/common"
 	"github.com/dominant-strategies/go-quai/consensus"
+	"github.com/dominant-strategies/go-quai/core/rawdb"
 	"github.com/dominant-strategies/go-quai/core/state/snapshot"
 	"github.com/dominant-strategies/go-quai/core/types"
 	"github.com/dominant-strategies/go-quai/eth/protocols/eth"
@@ -51,6 +52,7 @@ var (
 
 	maxQueuedHeaders  = 32 * 1024 // [eth/62] Maximum number of headers to queue for import (DOS protection)
 	maxHeadersProcess = 2048      // Number of header download results to import at once into the chain
+	maxImportBacklog  = 4096      // Maximum number of imported blocks ahead of the local head (backpressure from the core)
 
 	fsHeaderContCheck = 3 * time.Second // Time interval to check for header continuations during state download
 )
@@ -73,6 +75,10 @@ var (
 	errCanceled                = errors.New("syncing canceled (requested)")
 	errNoSyncActive            = errors.New("no sync active")
 	errTooOld                  = errors.New("peer's protocol version too old")
+
+	// ErrSyncTargetReached is returned by Synchronise once the configured sync
+	// target has been imported.
+	ErrSyncTargetReached = errors.New("sync target reached")
 )
 
 type Downloader struct {
@@ -82,7 +88,7 @@ type Downloader struct {
 	queue *queue   // Scheduler for selecting the hashes to download
 	peers *peerSet // Set of active peers from which download can proceed
 
-	stateDB ethdb.Database // Database to state sync into (and deduplicate via)
+	stateDB ethdb.Database // Database to persist the sync progress into
 
 	// Statistics
 	syncStatsChainOrigin uint64       // Origin block number where syncing started at
@@ -93,6 +99,7 @@ type Downloader struct {
 
 	headEntropy *big.Int
 	headNumber  uint64
+	syncTarget  uint64 // Block number to stop importing at, 0 if none (atomic)
 
 	// Callbacks
 	dropPeer peerDropFn // Drops a peer for misbehaving
@@ -132,6 +139,10 @@ type Core interface {
 	// GetBlockByHash retrieves a block from the local chain.
 	GetBlockByHash(common.Hash) *types.Block
 
+	// GetBlockOrCandidateByHash retrieves a block from the database, whether or
+	// not it has been appended to the local chain.
+	GetBlockOrCandidateByHash(common.Hash) *types.Block
+
 	// GetBlockByNumber retrieves a block from the local chain.
 	GetBlockByNumber(uint64) *types.Block
 
@@ -167,8 +178,9 @@ type Core interface {
 }
 
 // New creates a new downloader to fetch hashes and blocks from remote peers.
-func New(mux *event.TypeMux, core Core, dropPeer peerDropFn) *Downloader {
+func New(stateDb ethdb.Database, mux *event.TypeMux, core Core, dropPeer peerDropFn) *Downloader {
 	dl := &Downloader{
+		stateDB:      stateDb,
 		mux:          mux,
 		queue:        newQueue(blockCacheMaxItems, blockCacheInitialItems),
 		peers:        newPeerSet(),
@@ -182,10 +194,83 @@ func New(mux *event.TypeMux, core Core, dropPeer peerDropFn) *Downloader {
 		headerProcCh: make(chan []*types.Header, 10),
 		quitCh:       make(chan struct{}),
 	}
+	dl.resume()
 
 	return dl
 }
 
+// resume picks up an interrupted sync from the sync frontier persisted in the
+// database. The blocks downloaded beyond the local head are already on disk, so
+// rather than downloading them again they are handed back to the core to be
+// appended. The head of the downloader is left at the local head, as the blocks
+// may still turn out to be invalid.
+func (d *Downloader) resume() {
+	frontier := d.syncFrontier()
+	if frontier == nil {
+		return
+	}
+	// Walk back from the frontier until reaching the appended chain, collecting
+	// every block found on the way
+	var blocks []*types.Block
+	block := frontier
+	for block != nil && block.NumberU64() > d.headNumber && d.core.GetTerminiByHash(block.Hash()) == nil {
+		if d.core.IsBlockHashABadHash(block.Hash()) {
+			break
+		}
+		blocks = append(blocks, block)
+		block = d.core.GetBlockOrCandidateByHash(block.ParentHash())
+	}
+	if block == nil || d.core.IsBlockHashABadHash(block.Hash()) {
+		log.Warn("Discarding unusable sync frontier", "frontier", frontier.NumberU64(), "hash", frontier.Hash())
+		rawdb.DeleteSyncFrontierHash(d.stateDB)
+		return
+	}
+	for i := len(blocks) - 1; i >= 0; i-- {
+		d.core.WriteBlock(blocks[i])
+	}
+	log.Info("Resuming interrupted sync", "frontier", frontier.NumberU64(), "hash", frontier.Hash(), "queued", len(blocks))
+}
+
+// syncFrontier returns the persisted sync frontier, if it is still ahead of the
+// local head. A frontier which is unknown, bad or has fallen behind the local
+// head is deleted.
+func (d *Downloader) syncFrontier() *types.Block {
+	hash := rawdb.ReadSyncFrontierHash(d.stateDB)
+	if hash == (common.Hash{}) {
+		return nil
+	}
+	frontier := d.core.GetBlockOrCandidateByHash(hash)
+	if frontier == nil || frontier.NumberU64() <= d.core.CurrentHeader().NumberU64() || d.core.IsBlockHashABadHash(hash) {
+		rawdb.DeleteSyncFrontierHash(d.stateDB)
+		return nil
+	}
+	return frontier
+}
+
+// settleFrontier cleans up the sync frontier once a sync cycle is over. If the
+// cycle completed, the frontier is no longer needed. If the downloaded chain
+// was found to be invalid, the frontier is dropped and the head of the
+// downloader is reset to the local head, so that the dead chain does not shadow
+// honest peers.
+func (d *Downloader) settleFrontier(err error) {
+	// A cycle cut short by termination leaves the frontier to resume from
+	select {
+	case <-d.quitCh:
+		return
+	default:
+	}
+	switch {
+	case err == nil, errors.Is(err, errNoFetchesPending), errors.Is(err, ErrSyncTargetReached):
+		rawdb.DeleteSyncFrontierHash(d.stateDB)
+	case errors.Is(err, errInvalidChain), errors.Is(err, errBadBlockFound):
+		rawdb.DeleteSyncFrontierHash(d.stateDB)
+		d.headNumber = d.core.CurrentHeader().NumberU64()
+		d.headEntropy = d.core.CurrentLogEntropy()
+	default:
+		d.syncFrontier()
+	}
+}
+
 // Progress retrieves the synchronisation boundaries, specifically the origin
 // block where synchronisation started at (may have failed/suspended); the block
 // or header sync is currently at; and the latest known block which the sync targets.
@@ -256,7 +341,7 @@ func (d *Downloader) UnregisterPeer(id string) error {
 func (d *Downloader) Synchronise(id string, head common.Hash, entropy *big.Int, mode SyncMode) error {
 	err := d.synchronise(id, head, entropy, mode)
 	switch err {
-	case nil, errBusy, errCanceled, errNoFetchesPending:
+	case nil, errBusy, errCanceled, errNoFetchesPending, ErrSyncTargetReached:
 		return err
 	}
 	if errors.Is(err, errInvalidChain) || errors.Is(err, errBadPeer) || errors.Is(err, errTimeout) ||
@@ -276,6 +361,25 @@ func (d *Downloader) Synchronise(id string, head common.Hash, entropy *big.Int,
 	return err
 }
 
+// SetSyncTarget sets the block number past which downloaded blocks are not
+// imported. Zero removes the target.
+func (d *Downloader) SetSyncTarget(number uint64) {
+	atomic.StoreUint64(&d.syncTarget, number)
+}
+
+// SetRequestTimeoutLimit sets the maximum time allowed for a peer to answer a
+// sync request. Within the limit, the timeouts adapt to the measured round trip
+// times of the peers.
+func (d *Downloader) SetRequestTimeoutLimit(limit time.Duration) {
+	d.peers.rates.SetTimeoutLimit(limit)
+}
+
+// SyncTarget returns the block number past which downloaded blocks are not
+// imported, or zero if there is no target.
+func (d *Downloader) SyncTarget() uint64 {
+	return atomic.LoadUint64(&d.syncTarget)
+}
+
 // PeerSet retrieves the current peer set of the downloader.
 func (d *Downloader) PeerSet() *peerSet {
 	return d.peers
@@ -372,6 +476,7 @@ func (d *Downloader) syncWithPeer(p *peerConnection, hash common.Hash, entropy *
 			latest := d.core.CurrentHeader()
 			d.mux.Post(DoneEvent{latest})
 		}
+		d.settleFrontier(err)
 	}()
 	if p.version < eth.QUAI1 {
 		return fmt.Errorf("%w: advertized %d < required %d", errTooOld, p.version, eth.QUAI1)
@@ -1149,6 +1254,17 @@ func (d *Downloader) importBlockResults(results []*fetchResult) error {
 		return errCancelContentProcessing
 	default:
 	}
+	// If the core is lagging behind appending the blocks already imported, stall
+	// a bit. The result queue fills up meanwhile, throttling the body fetches.
+	for d.headNumber > d.core.CurrentHeader().NumberU64()+uint64(maxImportBacklog) {
+		select {
+		case <-d.quitCh:
+			return errCancelContentProcessing
+		case <-d.cancelCh:
+			return errCancelContentProcessing
+		case <-time.After(time.Second):
+		}
+	}
 	// Retrieve the a batch of results to import
 	first, last := results[0].Header, results[len(results)-1].Header
 	log.Info("Inserting downloaded chain", "items", len(results),
@@ -1156,14 +1272,30 @@ func (d *Downloader) importBlockResults(results []*fetchResult) error {
 		"lastnum", last.Number(), "lasthash", last.Hash(),
 	)
 
+	var (
+		target   = d.SyncTarget()
+		imported *types.Header
+	)
 	for _, result := range results {
 		block := types.NewBlockWithHeader(result.Header).WithBody(result.Transactions, result.Uncles, result.ExtTransactions, result.SubManifest)
+		if target != 0 && block.NumberU64() > target {
+			break
+		}
 		if d.core.IsBlockHashABadHash(block.Hash()) {
 			return errBadBlockFound
 		}
 		d.headNumber = block.NumberU64()
 		d.headEntropy = d.core.TotalLogS(block.Header())
 		d.core.WriteBlock(block)
+		imported = block.Header()
+	}
+	// Persist the progress, so the sync can resume from here after a restart
+	if imported != nil {
+		rawdb.WriteSyncFrontierHash(d.stateDB, imported.Hash())
+	}
+	if target != 0 && d.headNumber >= target {
+		log.Info("Sync target reached", "number", d.headNumber)
+		return ErrSyncTargetReached
 	}
 	return nil
 }
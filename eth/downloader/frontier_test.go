@@ -0,0 +1,181 @@
+// Copyright 2023 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package downloader
+
+import (
+	"fmt"
+	"math/big"
+	"testing"
+
+	"github.com/dominant-strategies/go-quai/common"
+	"github.com/dominant-strategies/go-quai/core/rawdb"
+	"github.com/dominant-strategies/go-quai/core/types"
+	"github.com/dominant-strategies/go-quai/event"
+)
+
+// frontierCore is a minimal Core holding a chain of blocks, of which the ones
+// up to head are appended and the rest are only stored.
+type frontierCore struct {
+	Core
+
+	blocks  []*types.Block
+	head    uint64
+	bad     map[common.Hash]bool
+	written []uint64
+}
+
+func newFrontierCore(n int, head uint64) *frontierCore {
+	c := &frontierCore{head: head, bad: make(map[common.Hash]bool)}
+	parent := common.Hash{}
+	for i := 0; i < n; i++ {
+		header := types.EmptyHeader()
+		header.SetNumber(big.NewInt(int64(i)))
+		header.SetParentHash(parent)
+		block := types.NewBlockWithHeader(header)
+		c.blocks = append(c.blocks, block)
+		parent = block.Hash()
+	}
+	return c
+}
+
+func (c *frontierCore) CurrentHeader() *types.Header { return c.blocks[c.head].Header() }
+func (c *frontierCore) CurrentLogEntropy() *big.Int  { return new(big.Int).SetUint64(c.head) }
+func (c *frontierCore) TotalLogS(header *types.Header) *big.Int {
+	return new(big.Int).SetUint64(header.NumberU64())
+}
+func (c *frontierCore) WriteBlock(block *types.Block) {
+	c.written = append(c.written, block.NumberU64())
+}
+func (c *frontierCore) IsBlockHashABadHash(hash common.Hash) bool {
+	return c.bad[hash]
+}
+
+func (c *frontierCore) GetBlockOrCandidateByHash(hash common.Hash) *types.Block {
+	for _, block := range c.blocks {
+		if block.Hash() == hash {
+			return block
+		}
+	}
+	return nil
+}
+
+func (c *frontierCore) GetTerminiByHash(hash common.Hash) *types.Termini {
+	if block := c.GetBlockOrCandidateByHash(hash); block != nil && block.NumberU64() <= c.head {
+		return &types.Termini{}
+	}
+	return nil
+}
+
+// Tests that an interrupted sync is resumed from the persisted frontier without
+// moving the head of the downloader past the appended chain.
+func TestResumeFrontier(t *testing.T) {
+	core := newFrontierCore(10, 4)
+	db := rawdb.NewMemoryDatabase()
+	rawdb.WriteSyncFrontierHash(db, core.blocks[8].Hash())
+
+	d := New(db, new(event.TypeMux), core, nil)
+	if want := []uint64{5, 6, 7, 8}; fmt.Sprint(core.written) != fmt.Sprint(want) {
+		t.Errorf("requeued blocks mismatch: have %v, want %v", core.written, want)
+	}
+	if d.headNumber != 4 || d.headEntropy.Uint64() != 4 {
+		t.Errorf("head moved to unappended frontier: number %d, entropy %v", d.headNumber, d.headEntropy)
+	}
+	if rawdb.ReadSyncFrontierHash(db) != core.blocks[8].Hash() {
+		t.Errorf("frontier dropped while still ahead of the local head")
+	}
+}
+
+// Tests that a frontier which can no longer be resumed from is deleted.
+func TestStaleFrontier(t *testing.T) {
+	// Frontier behind the local head
+	core := newFrontierCore(10, 6)
+	db := rawdb.NewMemoryDatabase()
+	rawdb.WriteSyncFrontierHash(db, core.blocks[3].Hash())
+	New(db, new(event.TypeMux), core, nil)
+	if rawdb.ReadSyncFrontierHash(db) != (common.Hash{}) || len(core.written) != 0 {
+		t.Errorf("frontier behind head: kept %v, requeued %v", rawdb.ReadSyncFrontierHash(db), core.written)
+	}
+	// Frontier unknown to the database
+	core = newFrontierCore(10, 4)
+	db = rawdb.NewMemoryDatabase()
+	rawdb.WriteSyncFrontierHash(db, common.HexToHash("0xdead"))
+	New(db, new(event.TypeMux), core, nil)
+	if rawdb.ReadSyncFrontierHash(db) != (common.Hash{}) || len(core.written) != 0 {
+		t.Errorf("unknown frontier: kept %v, requeued %v", rawdb.ReadSyncFrontierHash(db), core.written)
+	}
+	// Frontier on a chain containing a bad block
+	core = newFrontierCore(10, 4)
+	core.bad[core.blocks[6].Hash()] = true
+	db = rawdb.NewMemoryDatabase()
+	rawdb.WriteSyncFrontierHash(db, core.blocks[8].Hash())
+	New(db, new(event.TypeMux), core, nil)
+	if rawdb.ReadSyncFrontierHash(db) != (common.Hash{}) || len(core.written) != 0 {
+		t.Errorf("bad frontier chain: kept %v, requeued %v", rawdb.ReadSyncFrontierHash(db), core.written)
+	}
+	// Frontier on a chain not connected to the appended one
+	core = newFrontierCore(10, 4)
+	orphan := core.blocks[5:]
+	core.blocks = append(core.blocks[:5:5], orphan[1:]...)
+	db = rawdb.NewMemoryDatabase()
+	rawdb.WriteSyncFrontierHash(db, core.blocks[7].Hash())
+	New(db, new(event.TypeMux), core, nil)
+	if rawdb.ReadSyncFrontierHash(db) != (common.Hash{}) || len(core.written) != 0 {
+		t.Errorf("disconnected frontier: kept %v, requeued %v", rawdb.ReadSyncFrontierHash(db), core.written)
+	}
+}
+
+// Tests that the frontier is cleaned up according to the outcome of a sync.
+func TestSettleFrontier(t *testing.T) {
+	tests := []struct {
+		err  error
+		kept bool
+		head uint64
+	}{
+		{nil, false, 8},
+		{errNoFetchesPending, false, 8},
+		{ErrSyncTargetReached, false, 8},
+		{fmt.Errorf("%w: bad header", errInvalidChain), false, 4},
+		{errBadBlockFound, false, 4},
+		{errTimeout, true, 8},
+	}
+	for i, tt := range tests {
+		core := newFrontierCore(10, 4)
+		db := rawdb.NewMemoryDatabase()
+		d := New(db, new(event.TypeMux), core, nil)
+
+		d.headNumber, d.headEntropy = 8, big.NewInt(8)
+		rawdb.WriteSyncFrontierHash(db, core.blocks[8].Hash())
+		d.settleFrontier(tt.err)
+
+		if kept := rawdb.ReadSyncFrontierHash(db) != (common.Hash{}); kept != tt.kept {
+			t.Errorf("test %d (%v): frontier kept %v, want %v", i, tt.err, kept, tt.kept)
+		}
+		if d.headNumber != tt.head || d.headEntropy.Uint64() != tt.head {
+			t.Errorf("test %d (%v): head %d/%v, want %d", i, tt.err, d.headNumber, d.headEntropy, tt.head)
+		}
+	}
+	// A sync interrupted by termination keeps the frontier to resume from
+	core := newFrontierCore(10, 4)
+	db := rawdb.NewMemoryDatabase()
+	d := New(db, new(event.TypeMux), core, nil)
+	rawdb.WriteSyncFrontierHash(db, core.blocks[8].Hash())
+	d.Terminate()
+	d.settleFrontier(nil)
+	if rawdb.ReadSyncFrontierHash(db) == (common.Hash{}) {
+		t.Errorf("frontier dropped on termination")
+	}
+}
@@ -67,6 +67,16 @@ var Defaults = Config{
 	TrieDirtyCache:          256,
 	TrieTimeout:             60 * time.Minute,
 	SnapshotCache:           102,
+	CanonicalIndexCache:     8192,
+	MissingBlockCache:       4096,
+	MissingBlockTTL:         5 * time.Second,
+	HeaderCache:             512,
+	NumberCache:             2048,
+	BodyCache:               256,
+	BlockCache:              256,
+	TxLookupCache:           1024,
+	TipFetchTimeout:         5 * time.Second,
+	SyncRequestTimeout:      time.Minute,
 	Miner: core.Config{
 		GasCeil:  18000000,
 		GasPrice: big.NewInt(params.GWei),
@@ -118,6 +128,14 @@ type Config struct {
 	TrieTimeout             time.Duration
 	SnapshotCache           int
 	Preimages               bool
+	CanonicalIndexCache     uint64        // Number of recent canonical hashes to index in memory
+	MissingBlockCache       int           // Number of recently requested missing blocks to remember
+	MissingBlockTTL         time.Duration // Time during which a missing block is not requested again
+	HeaderCache             int           // Number of recent headers to cache in memory
+	NumberCache             int           // Number of recent hash to number lookups to cache in memory
+	BodyCache               int           // Number of recent block bodies to cache in memory
+	BlockCache              int           // Number of recent blocks to cache in memory
+	TxLookupCache           int           // Number of recent transaction lookups to cache in memory
 
 	// Mining options
 	Miner core.Config
@@ -164,6 +182,38 @@ type Config struct {
 
 	// Slices running on the node
 	SlicesRunning []common.Location
+
+	// RequestLogSample logs one in every RequestLogSample inbound and outbound
+	// protocol requests (0 = disabled).
+	RequestLogSample uint64
+
+	// BlockProvenance records the peer each recent block was first received from
+	// and the peers it was forwarded to.
+	BlockProvenance bool
+
+	// SyncTarget is the block number to sync up to, after which sync is paused
+	// until resumed through the admin API (0 = sync without stopping).
+	SyncTarget uint64
+
+	// ChainCheck periodically checks the consistency of the recent chain data,
+	// repairing or re-fetching inconsistent entries.
+	ChainCheck bool
+
+	// FirstSeen persists the first time every peer sent or announced each block
+	// and transaction into a separate research database.
+	FirstSeen bool
+
+	// ControlPeers are the nodes (enode URLs or node IDs) allowed to exchange
+	// control messages with the node. Empty disables the control protocol.
+	ControlPeers []string `toml:",omitempty"`
+
+	// TipFetchTimeout is the time allowed for a peer to return a block announced
+	// at the head of the chain.
+	TipFetchTimeout time.Duration
+
+	// SyncRequestTimeout caps the time allowed for a peer to answer a request for
+	// historical data while syncing.
+	SyncRequestTimeout time.Duration
 }
 
 // CreateProgpowConsensusEngine creates a progpow consensus engine for the given chain configuration.
@@ -66,7 +66,12 @@ var (
 	bodyFilterOutMeter   = metrics.NewRegisteredMeter("eth/fetcher/block/filter/bodies/out", nil)
 )
 
-var errTerminated = errors.New("terminated")
+var (
+	errTerminated    = errors.New("terminated")
+	errLowWork       = errors.New("block work is below half the current difficulty")
+	errEntropyTooFar = errors.New("block entropy is too far behind the current head")
+	errBadBlock      = errors.New("block is in the bad block list")
+)
 
 // blockRetrievalFn is a callback type for retrieving a block from the local chain.
 type blockRetrievalFn func(common.Hash) *types.Block
@@ -187,6 +192,8 @@ type BlockFetcher struct {
 	queue  *prque.Prque                         // Queue containing the import operations (block number sorted)
 	queued map[common.Hash]*blockOrHeaderInject // Set of already queued blocks (to dedup imports)
 
+	fetchTimeout time.Duration // Maximum allotted time to return an explicitly requested block
+
 	// Callbacks
 	getBlock            blockRetrievalFn    // Retrieves a block from the local chain
 	writeBlock          blockWriteFn        // Writes the block to the DB
@@ -224,6 +231,7 @@ func NewBlockFetcher(getBlock blockRetrievalFn, writeBlock blockWriteFn, verifyH
 		completing:          make(map[common.Hash]*blockAnnounce),
 		queue:               prque.New(nil),
 		queued:              make(map[common.Hash]*blockOrHeaderInject),
+		fetchTimeout:        fetchTimeout,
 		getBlock:            getBlock,
 		writeBlock:          writeBlock,
 		verifyHeader:        verifyHeader,
@@ -238,6 +246,12 @@ func NewBlockFetcher(getBlock blockRetrievalFn, writeBlock blockWriteFn, verifyH
 	}
 }
 
+// SetFetchTimeout sets the time allowed for a peer to return an explicitly
+// requested block. It must be called before the fetcher is started.
+func (f *BlockFetcher) SetFetchTimeout(timeout time.Duration) {
+	f.fetchTimeout = timeout
+}
+
 // Start boots up the announcement based synchroniser, accepting and processing
 // hash notifications and block fetches until termination requested.
 func (f *BlockFetcher) Start() {
@@ -342,7 +356,7 @@ func (f *BlockFetcher) loop() {
 	for {
 		// Clean up any expired block fetches
 		for hash, announce := range f.fetching {
-			if time.Since(announce.time) > fetchTimeout {
+			if time.Since(announce.time) > f.fetchTimeout {
 				f.forgetHash(hash)
 			}
 		}
@@ -715,17 +729,61 @@ func (f *BlockFetcher) enqueue(peer string, header *types.Header, block *types.B
 // the phase states accordingly.
 func (f *BlockFetcher) ImportBlocks(peer string, block *types.Block, relay bool) {
 	hash := block.Hash()
+	if err := f.checkBlock(peer, block, relay); err != nil {
+		return
+	}
+	// Run the import on a new thread
+	log.Debug("Importing propagated block", "peer", peer, "number", block.Number(), "hash", hash)
+	go func() {
+		defer func() { f.done <- hash }()
+
+		// If Block broadcasted by the peer exists in the bad block list drop the peer
+		if f.isBlockHashABadHash(block.Hash()) {
+			f.dropPeer(peer)
+			return
+		}
+		if err := f.verifyBlock(peer, block, relay); err != nil {
+			f.dropPeer(peer)
+			return
+		}
+		f.insertBlock(block, relay)
+	}()
+}
+
+// InjectBlock runs a block obtained outside the network through the same checks
+// as a propagated one, and inserts it into the chain, returning the reason if
+// the block is rejected. Unlike ImportBlocks, the block is processed on the
+// calling thread and no peer is ever dropped.
+func (f *BlockFetcher) InjectBlock(block *types.Block, relay bool) error {
+	if err := f.checkBlock("", block, relay); err != nil {
+		return err
+	}
+	if f.isBlockHashABadHash(block.Hash()) {
+		return errBadBlock
+	}
+	if err := f.verifyBlock("", block, relay); err != nil {
+		return err
+	}
+	f.insertBlock(block, relay)
+	return nil
+}
+
+// checkBlock runs the cheap checks of a block before it is imported: the seal,
+// the work relative to the current difficulty and, if the block is to be
+// relayed, the distance of its entropy to the current head. The peer is dropped
+// if its block is a little beyond the allowed entropy distance.
+func (f *BlockFetcher) checkBlock(peer string, block *types.Block, relay bool) error {
 	nodeCtx := common.NodeLocation.Context()
 
 	powhash, err := f.verifySeal(block.Header())
 	if err != nil {
-		return
+		return err
 	}
 	// Check if the Block is atleast half the current difficulty in Zone Context,
 	// this makes sure that the nodes don't listen to the forks with the PowHash
 	//	with less than 50% of current difficulty
 	if nodeCtx == common.ZONE_CTX && new(big.Int).SetBytes(powhash.Bytes()).Cmp(new(big.Int).Div(f.currentDifficulty(), big.NewInt(2))) < 0 {
-		return
+		return errLowWork
 	}
 
 	currentIntrinsicS := f.currentIntrinsicS()
@@ -737,64 +795,63 @@ func (f *BlockFetcher) ImportBlocks(peer string, block *types.Block, relay bool)
 
 	// If someone is mining not within MaxAllowableEntropyDist*currentIntrinsicS dont broadcast
 	if relay && f.currentS().Cmp(new(big.Int).Add(broadCastEntropy, MaxAllowableEntropyDist)) > 0 {
-		return
+		return errEntropyTooFar
 	}
 	// But don't drop the peers if within 1% of that distance
 	if relay && f.currentS().Cmp(new(big.Int).Add(broadCastEntropy, looseSyncEntropyDist)) > 0 {
-		if nodeCtx != common.PRIME_CTX {
+		if nodeCtx != common.PRIME_CTX && peer != "" {
 			f.dropPeer(peer)
 		}
-		return
+		return errEntropyTooFar
 	}
+	return nil
+}
 
-	// Run the import on a new thread
-	log.Debug("Importing propagated block", "peer", peer, "number", block.Number(), "hash", hash)
-	go func() {
-		defer func() { f.done <- hash }()
+// verifyBlock validates the header of a block, and propagates the block if it
+// passes and relay is set.
+func (f *BlockFetcher) verifyBlock(peer string, block *types.Block, relay bool) error {
+	// Quickly validate the header and propagate the block if it passes
+	err := f.verifyHeader(block.Header())
 
-		// If Block broadcasted by the peer exists in the bad block list drop the peer
-		if f.isBlockHashABadHash(block.Hash()) {
-			f.dropPeer(peer)
-			return
-		}
-		// Quickly validate the header and propagate the block if it passes
-		err := f.verifyHeader(block.Header())
-
-		// Including the ErrUnknownAncestor as well because a filter has already
-		// been applied for all the blocks that come until here. Since there
-		// exists a timedCache where the blocks expire, it is okay to let this
-		// block through and broadcast the block.
-		if err == nil || err.Error() == consensus.ErrUnknownAncestor.Error() {
-			// All ok, quickly propagate to our peers
-			blockBroadcastOutTimer.UpdateSince(block.ReceivedAt)
-
-			// Only relay the Mined Blocks that meet the depth criteria
-			if relay {
-				go f.broadcastBlock(block, true)
-			}
-		} else if err.Error() == consensus.ErrFutureBlock.Error() {
-			// Weird future block, don't fail, but neither propagate
-		} else {
-			// Something went very wrong, drop the peer
-			log.Debug("Propagated block verification failed", "peer", peer, "number", block.Number(), "hash", hash, "err", err)
-			f.dropPeer(peer)
-			return
-		}
-		// TODO: verify the Headers work to be in a certain threshold window
-		f.writeBlock(block)
-		// If import succeeded, broadcast the block
-		blockAnnounceOutTimer.UpdateSince(block.ReceivedAt)
+	// Including the ErrUnknownAncestor as well because a filter has already
+	// been applied for all the blocks that come until here. Since there
+	// exists a timedCache where the blocks expire, it is okay to let this
+	// block through and broadcast the block.
+	if err == nil || err.Error() == consensus.ErrUnknownAncestor.Error() {
+		// All ok, quickly propagate to our peers
+		blockBroadcastOutTimer.UpdateSince(block.ReceivedAt)
 
 		// Only relay the Mined Blocks that meet the depth criteria
 		if relay {
-			go f.broadcastBlock(block, false)
+			go f.broadcastBlock(block, true)
 		}
+	} else if err.Error() == consensus.ErrFutureBlock.Error() {
+		// Weird future block, don't fail, but neither propagate
+	} else {
+		// Something went very wrong
+		log.Debug("Propagated block verification failed", "peer", peer, "number", block.Number(), "hash", block.Hash(), "err", err)
+		return err
+	}
+	return nil
+}
 
-		// Invoke the testing hook if needed
-		if f.importedHook != nil {
-			f.importedHook(nil, block)
-		}
-	}()
+// insertBlock writes a verified block into the chain, and announces it if relay
+// is set.
+func (f *BlockFetcher) insertBlock(block *types.Block, relay bool) {
+	// TODO: verify the Headers work to be in a certain threshold window
+	f.writeBlock(block)
+	// If import succeeded, broadcast the block
+	blockAnnounceOutTimer.UpdateSince(block.ReceivedAt)
+
+	// Only relay the Mined Blocks that meet the depth criteria
+	if relay {
+		go f.broadcastBlock(block, false)
+	}
+
+	// Invoke the testing hook if needed
+	if f.importedHook != nil {
+		f.importedHook(nil, block)
+	}
 }
 
 // forgetHash removes all traces of a block announcement from the fetcher's
@@ -28,14 +28,32 @@ import (
 	quai "github.com/dominant-strategies/go-quai"
 	"github.com/dominant-strategies/go-quai/common"
 	"github.com/dominant-strategies/go-quai/common/hexutil"
+	"github.com/dominant-strategies/go-quai/core"
 	"github.com/dominant-strategies/go-quai/core/types"
 	"github.com/dominant-strategies/go-quai/ethdb"
+	"github.com/dominant-strategies/go-quai/ethdb/memorydb"
 	"github.com/dominant-strategies/go-quai/event"
+	"github.com/dominant-strategies/go-quai/log"
+	"github.com/dominant-strategies/go-quai/rlp"
 	"github.com/dominant-strategies/go-quai/rpc"
+	"github.com/dominant-strategies/go-quai/trie"
+	lru "github.com/hashicorp/golang-lru"
 )
 
 const (
 	c_pendingHeaderChSize = 20
+	c_chainEventChSize    = 10
+
+	// c_logsCacheSize is the Max number of getLogs responses to cache
+	c_logsCacheSize = 256
+
+	// c_logsCacheDepth is the number of blocks behind the head past which a
+	// range of blocks is considered unlikely to be reorged, and its logs cached
+	c_logsCacheDepth = 16
+
+	// c_etxFinalityDepth is the default number of blocks an external transaction
+	// must be buried under before the etxs subscription notifies it
+	c_etxFinalityDepth = 16
 )
 
 // filter is a helper struct that holds meta information over the filter type
@@ -60,16 +78,26 @@ type PublicFilterAPI struct {
 	filtersMu sync.Mutex
 	filters   map[rpc.ID]*filter
 	timeout   time.Duration
+	logsCache *lru.Cache // Cache of getLogs responses over settled block ranges
+}
+
+// cachedLogs is a cached getLogs response, along with the hash of the last block
+// of the range at the time, to detect reorgs of the range.
+type cachedLogs struct {
+	hash common.Hash
+	logs []*types.Log
 }
 
 // NewPublicFilterAPI returns a new PublicFilterAPI instance.
 func NewPublicFilterAPI(backend Backend, lightMode bool, timeout time.Duration) *PublicFilterAPI {
+	logsCache, _ := lru.New(c_logsCacheSize)
 	api := &PublicFilterAPI{
-		backend: backend,
-		chainDb: backend.ChainDb(),
-		events:  NewEventSystem(backend, lightMode),
-		filters: make(map[rpc.ID]*filter),
-		timeout: timeout,
+		backend:   backend,
+		chainDb:   backend.ChainDb(),
+		events:    NewEventSystem(backend, lightMode),
+		filters:   make(map[rpc.ID]*filter),
+		timeout:   timeout,
+		logsCache: logsCache,
 	}
 	go api.timeoutLoop(timeout)
 
@@ -351,6 +379,20 @@ func (api *PublicFilterAPI) GetLogs(ctx context.Context, crit FilterCriteria) ([
 		if crit.ToBlock != nil {
 			end = crit.ToBlock.Int64()
 		}
+		// Serve settled ranges from the cache if possible
+		if hash, ok := api.settledBlock(ctx, begin, end); ok {
+			key := fmt.Sprintf("%d-%d-%v-%v", begin, end, crit.Addresses, crit.Topics)
+			if entry, ok := api.logsCache.Get(key); ok && entry.(cachedLogs).hash == hash {
+				return entry.(cachedLogs).logs, nil
+			}
+			logs, err := NewRangeFilter(api.backend, begin, end, crit.Addresses, crit.Topics).Logs(ctx)
+			if err != nil {
+				return nil, err
+			}
+			logs = returnLogs(logs)
+			api.logsCache.Add(key, cachedLogs{hash: hash, logs: logs})
+			return logs, nil
+		}
 		// Construct the range filter
 		filter = NewRangeFilter(api.backend, begin, end, crit.Addresses, crit.Topics)
 	}
@@ -362,6 +404,25 @@ func (api *PublicFilterAPI) GetLogs(ctx context.Context, crit FilterCriteria) ([
 	return returnLogs(logs), err
 }
 
+// settledBlock reports whether the given block range lies deep enough in the
+// chain for its logs to be cached, returning the hash of the last block in the
+// range. As every block commits to its parent, a reorg anywhere in the range
+// changes that hash.
+func (api *PublicFilterAPI) settledBlock(ctx context.Context, begin, end int64) (common.Hash, bool) {
+	if begin < 0 || end < begin {
+		return common.Hash{}, false
+	}
+	head, err := api.backend.HeaderByNumber(ctx, rpc.LatestBlockNumber)
+	if head == nil || err != nil || uint64(end)+c_logsCacheDepth > head.NumberU64() {
+		return common.Hash{}, false
+	}
+	header, err := api.backend.HeaderByNumber(ctx, rpc.BlockNumber(end))
+	if header == nil || err != nil {
+		return common.Hash{}, false
+	}
+	return header.Hash(), true
+}
+
 // UninstallFilter removes the filter with the given filter id.
 //
 // https://eth.wiki/json-rpc/API#eth_uninstallfilter
@@ -619,3 +680,258 @@ func (api *PublicFilterAPI) PendingHeader(ctx context.Context) (*rpc.Subscriptio
 
 	return rpcSub, nil
 }
+
+// EtxResult is an external transaction emitted by a block, together with the
+// Merkle proof of its inclusion under the block's etx root.
+type EtxResult struct {
+	BlockHash   common.Hash        `json:"blockHash"`
+	BlockNumber *hexutil.Big       `json:"blockNumber"`
+	EtxHash     common.Hash        `json:"etxHash"`
+	Index       hexutil.Uint64     `json:"index"`
+	Etx         *types.Transaction `json:"etx"`
+	Proof       []hexutil.Bytes    `json:"proof"`
+}
+
+// proofList collects the trie nodes of a Merkle proof.
+type proofList []hexutil.Bytes
+
+func (n *proofList) Put(key []byte, value []byte) error {
+	*n = append(*n, value)
+	return nil
+}
+
+func (n *proofList) Delete(key []byte) error {
+	panic("not supported")
+}
+
+// etxResults returns the external transactions emitted by the given block along
+// with their inclusion proofs.
+func etxResults(block *types.Block) ([]*EtxResult, error) {
+	etxs := block.ExtTransactions()
+	if len(etxs) == 0 {
+		return []*EtxResult{}, nil
+	}
+	tr, err := trie.New(common.Hash{}, trie.NewDatabase(memorydb.New()))
+	if err != nil {
+		return nil, err
+	}
+	if root := types.DeriveSha(etxs, tr); root != block.EtxHash() {
+		return nil, fmt.Errorf("etx root mismatch: have %x, want %x", root, block.EtxHash())
+	}
+	results := make([]*EtxResult, len(etxs))
+	for i, etx := range etxs {
+		var proof proofList
+		if err := tr.Prove(rlp.AppendUint64(nil, uint64(i)), 0, &proof); err != nil {
+			return nil, err
+		}
+		results[i] = &EtxResult{
+			BlockHash:   block.Hash(),
+			BlockNumber: (*hexutil.Big)(block.Number()),
+			EtxHash:     etx.Hash(),
+			Index:       hexutil.Uint64(i),
+			Etx:         etx,
+			Proof:       proof,
+		}
+	}
+	return results, nil
+}
+
+// BridgeLogResult is a receipt with logs relevant to a bridge, together with
+// the Merkle proof of its inclusion under the block's receipt root.
+type BridgeLogResult struct {
+	BlockHash   common.Hash     `json:"blockHash"`
+	BlockNumber *hexutil.Big    `json:"blockNumber"`
+	TxHash      common.Hash     `json:"transactionHash"`
+	Index       hexutil.Uint64  `json:"index"`
+	Receipt     *types.Receipt  `json:"receipt"`
+	Logs        []*types.Log    `json:"logs"`
+	Proof       []hexutil.Bytes `json:"proof"`
+}
+
+// bridgeLogResults returns the receipts of the given block with logs matching
+// the criteria, along with their inclusion proofs.
+func (api *PublicFilterAPI) bridgeLogResults(ctx context.Context, block *types.Block, crit FilterCriteria) ([]*BridgeLogResult, error) {
+	receipts, err := api.backend.GetReceipts(ctx, block.Hash())
+	if err != nil {
+		return nil, err
+	}
+	if len(receipts) == 0 {
+		return []*BridgeLogResult{}, nil
+	}
+	tr, err := trie.New(common.Hash{}, trie.NewDatabase(memorydb.New()))
+	if err != nil {
+		return nil, err
+	}
+	if root := types.DeriveSha(receipts, tr); root != block.ReceiptHash() {
+		return nil, fmt.Errorf("receipt root mismatch: have %x, want %x", root, block.ReceiptHash())
+	}
+	results := []*BridgeLogResult{}
+	for i, receipt := range receipts {
+		logs := filterLogs(receipt.Logs, nil, nil, crit.Addresses, crit.Topics)
+		if len(logs) == 0 {
+			continue
+		}
+		var proof proofList
+		if err := tr.Prove(rlp.AppendUint64(nil, uint64(i)), 0, &proof); err != nil {
+			return nil, err
+		}
+		results = append(results, &BridgeLogResult{
+			BlockHash:   block.Hash(),
+			BlockNumber: (*hexutil.Big)(block.Number()),
+			TxHash:      receipt.TxHash,
+			Index:       hexutil.Uint64(i),
+			Receipt:     receipt,
+			Logs:        logs,
+			Proof:       proof,
+		})
+	}
+	return results, nil
+}
+
+// Etxs sends a notification for every external transaction emitted by a
+// canonical block, along with the proof of its inclusion, once the block is
+// buried under the given number of blocks (16 by default). Every height is
+// notified once, in order, so reorgs shallower than the depth are never seen by
+// the subscriber. Deeper reorgs are not reported.
+func (api *PublicFilterAPI) Etxs(ctx context.Context, depth *hexutil.Uint64) (*rpc.Subscription, error) {
+	return api.subscribeFinal(ctx, depth, func(block *types.Block) ([]interface{}, error) {
+		results, err := etxResults(block)
+		if err != nil {
+			return nil, err
+		}
+		items := make([]interface{}, len(results))
+		for i, result := range results {
+			items[i] = result
+		}
+		return items, nil
+	})
+}
+
+// BridgeLogs sends a notification for every receipt of a canonical block with
+// logs matching the given criteria, along with the proof of its inclusion, once
+// the block is buried under the given number of blocks (16 by default). The
+// block range of the criteria is ignored. Like the etxs subscription, every
+// height is notified once, in order.
+func (api *PublicFilterAPI) BridgeLogs(ctx context.Context, crit FilterCriteria, depth *hexutil.Uint64) (*rpc.Subscription, error) {
+	return api.subscribeFinal(ctx, depth, func(block *types.Block) ([]interface{}, error) {
+		results, err := api.bridgeLogResults(context.Background(), block, crit)
+		if err != nil {
+			return nil, err
+		}
+		items := make([]interface{}, len(results))
+		for i, result := range results {
+			items[i] = result
+		}
+		return items, nil
+	})
+}
+
+// subscribeFinal notifies the subscriber of the items collected from every
+// canonical block once the block is buried under the given number of blocks.
+// A height whose items cannot be collected is retried on the next chain event,
+// so no height is skipped. The subscription ends if the chain events stop.
+func (api *PublicFilterAPI) subscribeFinal(ctx context.Context, depth *hexutil.Uint64, collect func(block *types.Block) ([]interface{}, error)) (*rpc.Subscription, error) {
+	notifier, supported := rpc.NotifierFromContext(ctx)
+	if !supported {
+		return &rpc.Subscription{}, rpc.ErrNotificationsUnsupported
+	}
+	confirmations := uint64(c_etxFinalityDepth)
+	if depth != nil {
+		confirmations = uint64(*depth)
+	}
+
+	rpcSub := notifier.CreateSubscription()
+
+	go func() {
+		chainEvents := make(chan core.ChainEvent, c_chainEventChSize)
+		chainSub := api.backend.SubscribeChainEvent(chainEvents)
+		defer chainSub.Unsubscribe()
+
+		var (
+			next    uint64 // Next height to notify the items of
+			started bool   // Whether the first height to notify is known
+		)
+		for {
+			select {
+			case ev := <-chainEvents:
+				head := ev.Block.NumberU64()
+				if head < confirmations {
+					continue
+				}
+				final := head - confirmations
+				if !started {
+					next, started = final, true
+				}
+				for ; next <= final; next++ {
+					items, err := api.finalItems(next, collect)
+					if err != nil {
+						log.Warn("Failed to collect final block items, retrying", "number", next, "err", err)
+						break
+					}
+					for _, item := range items {
+						notifier.Notify(rpcSub.ID, item)
+					}
+				}
+			case err := <-chainSub.Err():
+				log.Warn("Chain event subscription failed, ending final block subscription", "err", err)
+				return
+			case <-rpcSub.Err():
+				return
+			case <-notifier.Closed():
+				return
+			}
+		}
+	}()
+
+	return rpcSub, nil
+}
+
+// finalItems collects the items of the canonical block at the given height.
+func (api *PublicFilterAPI) finalItems(number uint64, collect func(block *types.Block) ([]interface{}, error)) ([]interface{}, error) {
+	ctx := context.Background()
+	header, err := api.backend.HeaderByNumber(ctx, rpc.BlockNumber(number))
+	if err != nil {
+		return nil, err
+	}
+	if header == nil {
+		return nil, errors.New("unknown block")
+	}
+	block, err := api.backend.BlockByHash(ctx, header.Hash())
+	if err != nil {
+		return nil, err
+	}
+	if block == nil {
+		return nil, errors.New("unknown block")
+	}
+	return collect(block)
+}
+
+// GetEtxs returns the external transactions emitted by the block with the given
+// hash along with the proofs of their inclusion. Together with a block filter
+// it is the polling counterpart of the etxs subscription, though the caller is
+// then responsible for waiting until the block is final.
+func (api *PublicFilterAPI) GetEtxs(ctx context.Context, blockHash common.Hash) ([]*EtxResult, error) {
+	block, err := api.backend.BlockByHash(ctx, blockHash)
+	if err != nil {
+		return nil, err
+	}
+	if block == nil {
+		return nil, errors.New("unknown block")
+	}
+	return etxResults(block)
+}
+
+// GetBridgeLogs returns the receipts of the block with the given hash with logs
+// matching the criteria, along with the proofs of their inclusion. It is the
+// polling counterpart of the bridgeLogs subscription, though the caller is then
+// responsible for waiting until the block is final.
+func (api *PublicFilterAPI) GetBridgeLogs(ctx context.Context, blockHash common.Hash, crit FilterCriteria) ([]*BridgeLogResult, error) {
+	block, err := api.backend.BlockByHash(ctx, blockHash)
+	if err != nil {
+		return nil, err
+	}
+	if block == nil {
+		return nil, errors.New("unknown block")
+	}
+	return api.bridgeLogResults(ctx, block, crit)
+}
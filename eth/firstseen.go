@@ -0,0 +1,257 @@
+// Copyright 2023 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package eth
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/dominant-strategies/go-quai/common"
+	"github.com/dominant-strategies/go-quai/core/types"
+	"github.com/dominant-strategies/go-quai/ethdb"
+	"github.com/dominant-strategies/go-quai/log"
+	"github.com/dominant-strategies/go-quai/metrics"
+)
+
+const (
+	// c_firstSeenQueueSize is the Max number of sightings waiting to be written
+	// to the database, further ones are dropped
+	c_firstSeenQueueSize = 16384
+)
+
+var (
+	// The sightings are keyed by kind prefix + item hash + peer id, and hold the
+	// time of the sighting in unix nanoseconds
+	firstSeenBlockPrefix = []byte("b")
+	firstSeenTxPrefix    = []byte("t")
+
+	firstSeenDroppedMeter = metrics.NewRegisteredMeter("eth/firstseen/dropped", nil)
+)
+
+// FirstSeen is the first time an item was sent or announced by a peer.
+type FirstSeen struct {
+	Kind string    `json:"kind"` // "block" or "tx"
+	Peer string    `json:"peer"`
+	Time time.Time `json:"time"`
+}
+
+type sighting struct {
+	prefix []byte
+	hash   common.Hash
+	peer   string
+	at     time.Time
+}
+
+// firstSeenRecorder persists the first time every peer sent or announced each
+// block and transaction, for latency and ordering research. The sightings are
+// written asynchronously in batches, so that recording never blocks message
+// handling. A nil recorder is valid and records nothing.
+type firstSeenRecorder struct {
+	db    ethdb.Database
+	queue chan sighting
+	quit  chan struct{}
+	wg    sync.WaitGroup
+}
+
+func newFirstSeenRecorder(db ethdb.Database) *firstSeenRecorder {
+	return &firstSeenRecorder{
+		db:    db,
+		queue: make(chan sighting, c_firstSeenQueueSize),
+		quit:  make(chan struct{}),
+	}
+}
+
+func (r *firstSeenRecorder) start() {
+	if r == nil {
+		return
+	}
+	r.wg.Add(1)
+	go r.loop()
+}
+
+func (r *firstSeenRecorder) stop() {
+	if r == nil {
+		return
+	}
+	close(r.quit)
+	r.wg.Wait()
+}
+
+// recordBlocks records the sighting of the given blocks from a peer.
+func (r *firstSeenRecorder) recordBlocks(peer string, at time.Time, hashes ...common.Hash) {
+	r.record(firstSeenBlockPrefix, peer, at, hashes)
+}
+
+// recordTxs records the sighting of the given transaction hashes from a peer.
+func (r *firstSeenRecorder) recordTxs(peer string, at time.Time, hashes ...common.Hash) {
+	r.record(firstSeenTxPrefix, peer, at, hashes)
+}
+
+// recordTransactions records the sighting of the given transactions from a peer.
+func (r *firstSeenRecorder) recordTransactions(peer string, at time.Time, txs []*types.Transaction) {
+	if r == nil {
+		return
+	}
+	hashes := make([]common.Hash, len(txs))
+	for i, tx := range txs {
+		hashes[i] = tx.Hash()
+	}
+	r.record(firstSeenTxPrefix, peer, at, hashes)
+}
+
+func (r *firstSeenRecorder) record(prefix []byte, peer string, at time.Time, hashes []common.Hash) {
+	if r == nil {
+		return
+	}
+	for _, hash := range hashes {
+		select {
+		case r.queue <- sighting{prefix: prefix, hash: hash, peer: peer, at: at}:
+		default:
+			firstSeenDroppedMeter.Mark(1)
+		}
+	}
+}
+
+// loop writes the queued sightings to the database, skipping those already
+// recorded for the same item and peer. The sightings still queued on shutdown
+// are written before returning.
+func (r *firstSeenRecorder) loop() {
+	defer r.wg.Done()
+
+	for {
+		select {
+		case s := <-r.queue:
+			r.writeBatch(s)
+		case <-r.quit:
+			// Flush the sightings queued before the shutdown
+			for {
+				select {
+				case s := <-r.queue:
+					r.writeBatch(s)
+				default:
+					return
+				}
+			}
+		}
+	}
+}
+
+// writeBatch writes the given sighting along with those queued behind it, up to
+// the ideal batch size.
+func (r *firstSeenRecorder) writeBatch(s sighting) {
+	batch := r.db.NewBatch()
+	written := make(map[string]struct{})
+	r.write(batch, written, s)
+drain:
+	for batch.ValueSize() < ethdb.IdealBatchSize {
+		select {
+		case s := <-r.queue:
+			r.write(batch, written, s)
+		default:
+			break drain
+		}
+	}
+	if err := batch.Write(); err != nil {
+		log.Error("Failed to write first seen records", "err", err)
+	}
+}
+
+func (r *firstSeenRecorder) write(batch ethdb.Batch, written map[string]struct{}, s sighting) {
+	key := firstSeenKey(s.prefix, s.hash, s.peer)
+	if _, ok := written[string(key)]; ok {
+		return
+	}
+	if has, _ := r.db.Has(key); has {
+		return
+	}
+	var value [8]byte
+	binary.BigEndian.PutUint64(value[:], uint64(s.at.UnixNano()))
+	batch.Put(key, value[:])
+	written[string(key)] = struct{}{}
+}
+
+// get retrieves the sightings of a block or transaction, ordered by time.
+func (r *firstSeenRecorder) get(hash common.Hash) []FirstSeen {
+	var seen []FirstSeen
+	for _, prefix := range [][]byte{firstSeenBlockPrefix, firstSeenTxPrefix} {
+		it := r.db.NewIterator(append(common.CopyBytes(prefix), hash.Bytes()...), nil)
+		for it.Next() {
+			if entry, _, ok := parseFirstSeen(it.Key(), it.Value()); ok {
+				seen = append(seen, entry)
+			}
+		}
+		it.Release()
+	}
+	sort.Slice(seen, func(i, j int) bool { return seen[i].Time.Before(seen[j].Time) })
+	return seen
+}
+
+// export writes all the recorded sightings to the writer as CSV lines of kind,
+// hash, peer and time in unix nanoseconds.
+func (r *firstSeenRecorder) export(w io.Writer) (int, error) {
+	if _, err := fmt.Fprintln(w, "kind,hash,peer,time"); err != nil {
+		return 0, err
+	}
+	it := r.db.NewIterator(nil, nil)
+	defer it.Release()
+
+	count := 0
+	for it.Next() {
+		entry, hash, ok := parseFirstSeen(it.Key(), it.Value())
+		if !ok {
+			continue
+		}
+		if _, err := fmt.Fprintf(w, "%s,%s,%s,%d\n", entry.Kind, hash.Hex(), entry.Peer, entry.Time.UnixNano()); err != nil {
+			return count, err
+		}
+		count++
+	}
+	return count, it.Error()
+}
+
+func firstSeenKey(prefix []byte, hash common.Hash, peer string) []byte {
+	key := make([]byte, 0, len(prefix)+common.HashLength+len(peer))
+	key = append(key, prefix...)
+	key = append(key, hash.Bytes()...)
+	return append(key, peer...)
+}
+
+func parseFirstSeen(key, value []byte) (FirstSeen, common.Hash, bool) {
+	if len(key) <= 1+common.HashLength || len(value) != 8 {
+		return FirstSeen{}, common.Hash{}, false
+	}
+	var kind string
+	switch {
+	case bytes.HasPrefix(key, firstSeenBlockPrefix):
+		kind = "block"
+	case bytes.HasPrefix(key, firstSeenTxPrefix):
+		kind = "tx"
+	default:
+		return FirstSeen{}, common.Hash{}, false
+	}
+	entry := FirstSeen{
+		Kind: kind,
+		Peer: string(key[1+common.HashLength:]),
+		Time: time.Unix(0, int64(binary.BigEndian.Uint64(value))),
+	}
+	return entry, common.BytesToHash(key[1 : 1+common.HashLength]), true
+}
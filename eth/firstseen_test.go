@@ -0,0 +1,103 @@
+// Copyright 2023 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package eth
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/dominant-strategies/go-quai/common"
+	"github.com/dominant-strategies/go-quai/core/rawdb"
+)
+
+// Tests that the database keys and values of sightings can be parsed back.
+func TestFirstSeenEncoding(t *testing.T) {
+	hash := common.HexToHash("0xdeadbeef")
+	at := time.Unix(1700000000, 123456789)
+
+	for prefix, kind := range map[string]string{string(firstSeenBlockPrefix): "block", string(firstSeenTxPrefix): "tx"} {
+		db := rawdb.NewMemoryDatabase()
+		r := newFirstSeenRecorder(db)
+		batch := db.NewBatch()
+		r.write(batch, make(map[string]struct{}), sighting{prefix: []byte(prefix), hash: hash, peer: "peer1", at: at})
+		if err := batch.Write(); err != nil {
+			t.Fatalf("failed to write sighting: %v", err)
+		}
+		key := firstSeenKey([]byte(prefix), hash, "peer1")
+		value, err := db.Get(key)
+		if err != nil {
+			t.Fatalf("%s: sighting not stored: %v", kind, err)
+		}
+		entry, parsed, ok := parseFirstSeen(key, value)
+		if !ok {
+			t.Fatalf("%s: failed to parse sighting", kind)
+		}
+		if parsed != hash || entry.Kind != kind || entry.Peer != "peer1" || !entry.Time.Equal(at) {
+			t.Errorf("%s: parsed sighting mismatch: have %v %+v", kind, parsed, entry)
+		}
+	}
+	// Keys without a peer or of an unknown kind are not sightings
+	if _, _, ok := parseFirstSeen(append([]byte("b"), hash.Bytes()...), make([]byte, 8)); ok {
+		t.Errorf("parsed a key without a peer")
+	}
+	if _, _, ok := parseFirstSeen(firstSeenKey([]byte("x"), hash, "peer1"), make([]byte, 8)); ok {
+		t.Errorf("parsed a key of an unknown kind")
+	}
+}
+
+// Tests that only the first sighting of an item from every peer is kept, and
+// that sightings queued on shutdown are written.
+func TestFirstSeenDedup(t *testing.T) {
+	var (
+		db    = rawdb.NewMemoryDatabase()
+		r     = newFirstSeenRecorder(db)
+		hash  = common.HexToHash("0x01")
+		start = time.Unix(1700000000, 0)
+	)
+	r.start()
+	r.recordBlocks("peer1", start, hash)
+	r.recordBlocks("peer1", start.Add(time.Second), hash)
+	r.recordBlocks("peer2", start.Add(2*time.Second), hash)
+	r.recordTxs("peer1", start.Add(3*time.Second), hash)
+	r.stop()
+
+	// Sightings arriving in later batches mustn't overwrite the first either
+	r = newFirstSeenRecorder(db)
+	r.start()
+	r.recordBlocks("peer2", start.Add(-time.Second), hash)
+	r.stop()
+
+	seen := r.get(hash)
+	want := []FirstSeen{
+		{Kind: "block", Peer: "peer1", Time: start},
+		{Kind: "block", Peer: "peer2", Time: start.Add(2 * time.Second)},
+		{Kind: "tx", Peer: "peer1", Time: start.Add(3 * time.Second)},
+	}
+	if len(seen) != len(want) {
+		t.Fatalf("sightings mismatch: have %+v, want %+v", seen, want)
+	}
+	for i := range want {
+		if seen[i].Kind != want[i].Kind || seen[i].Peer != want[i].Peer || !seen[i].Time.Equal(want[i].Time) {
+			t.Errorf("sighting %d mismatch: have %+v, want %+v", i, seen[i], want[i])
+		}
+	}
+	var out bytes.Buffer
+	if n, err := r.export(&out); err != nil || n != len(want) {
+		t.Errorf("export mismatch: have %d (%v), want %d", n, err, len(want))
+	}
+}
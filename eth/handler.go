@@ -26,6 +26,7 @@ import (
 	"time"
 
 	"github.com/dominant-strategies/go-quai/common"
+	"github.com/dominant-strategies/go-quai/common/timedcache"
 	"github.com/dominant-strategies/go-quai/core"
 	"github.com/dominant-strategies/go-quai/core/forkid"
 	"github.com/dominant-strategies/go-quai/core/types"
@@ -35,6 +36,7 @@ import (
 	"github.com/dominant-strategies/go-quai/ethdb"
 	"github.com/dominant-strategies/go-quai/event"
 	"github.com/dominant-strategies/go-quai/log"
+	"github.com/dominant-strategies/go-quai/metrics"
 	"github.com/dominant-strategies/go-quai/p2p"
 	lru "github.com/hashicorp/golang-lru"
 )
@@ -60,13 +62,34 @@ const (
 	// minPeerSendTx is the minimum number of peers that will receive a new transaction.
 	minPeerSendTx = 2
 
+	// minHealthyBroadcastPeers is the number of connected peers below which a
+	// broadcast block is considered unlikely to propagate through the network.
+	minHealthyBroadcastPeers = 3
+
 	// c_broadcastCacheSize is the Max number of broadcast block hashes to be kept for Logging
 	c_broadcastCacheSize = 10
 
 	// c_subSyncCacheSize is the Max number of block hashes requested from peers
 	c_subSyncCacheSize = 100000
+
+	// c_missingBlockCacheSize is the Max number of recently requested missing
+	// block hashes to remember
+	c_missingBlockCacheSize = 4096
+
+	// c_missingBlockRequestTTL is the time (in seconds) during which a missing
+	// block is not requested again from the peers after a failed lookup
+	c_missingBlockRequestTTL = 5
+
+	// c_maxMissingBlockRetries is the Max number of times a block reported missing
+	// by a peer is requested again from other peers, before waiting for its
+	// negative cache entry to expire
+	c_maxMissingBlockRetries = 2
 )
 
+// unhealthyBroadcastMeter counts the blocks broadcast while too few peers were
+// connected for them to reliably propagate.
+var unhealthyBroadcastMeter = metrics.NewRegisteredMeter("eth/broadcast/unhealthy", nil)
+
 // txPool defines the methods needed from a transaction pool implementation to
 // support all the operations needed by the Quai chain protocols.
 type txPool interface {
@@ -102,6 +125,15 @@ type handlerConfig struct {
 	EventMux      *event.TypeMux         // Legacy event mux, deprecate for `feed`
 	Whitelist     map[uint64]common.Hash // Hard coded whitelist for sync challenged
 	SlicesRunning []common.Location      // Slices run by the node
+	Provenance    bool                   // Whether to record the propagation path of recent blocks
+	SyncTarget    uint64                 // Block number to sync to and pause at (0 = none)
+	ChainCheck    bool                   // Whether to periodically check the consistency of the recent chain
+	FirstSeenDB   ethdb.Database         // Database to record first seen blocks and transactions into (nil = disabled)
+	TipTimeout    time.Duration          // Time allowed for a peer to return an announced block (0 = default)
+	SyncTimeout   time.Duration          // Time allowed for a peer to answer a sync request (0 = default)
+
+	MissingBlockCache int           // Number of recently requested missing blocks to remember (0 = default)
+	MissingBlockTTL   time.Duration // Time during which a missing block is not requested again (0 = default)
 }
 
 type handler struct {
@@ -109,7 +141,8 @@ type handler struct {
 	forkFilter    forkid.Filter     // Fork ID filter, constant across the lifetime of the node
 	slicesRunning []common.Location // Slices running on the node
 
-	acceptTxs uint32 // Flag whether we're considered synchronised (enables transaction processing)
+	acceptTxs  uint32 // Flag whether we're considered synchronised (enables transaction processing)
+	syncPaused uint32 // Flag whether chain sync was paused by the operator
 
 	database ethdb.Database
 	txpool   txPool
@@ -128,6 +161,10 @@ type handler struct {
 	missingBlockCh  chan types.BlockRequest
 	missingBlockSub event.Subscription
 	subSyncQueue    *lru.Cache
+	missingBlocks   *timedcache.TimedCache // Negative cache of recently requested missing blocks
+	provenance      *provenanceTracker     // Propagation paths of recent blocks (nil if disabled)
+	chainCheck      bool                   // Whether to periodically check the consistency of the recent chain
+	firstSeen       *firstSeenRecorder     // First sightings of blocks and transactions (nil if disabled)
 
 	whitelist map[uint64]common.Hash
 
@@ -160,6 +197,7 @@ func newHandler(config *handlerConfig) (*handler, error) {
 		core:          config.Core,
 		peers:         newPeerSet(),
 		whitelist:     config.Whitelist,
+		chainCheck:    config.ChainCheck,
 		txsyncCh:      make(chan *txsync),
 		quitSync:      make(chan struct{}),
 	}
@@ -170,7 +208,28 @@ func newHandler(config *handlerConfig) (*handler, error) {
 	subSyncQueue, _ := lru.New(c_subSyncCacheSize)
 	h.subSyncQueue = subSyncQueue
 
-	h.downloader = downloader.New(h.eventMux, h.core, h.removePeer)
+	missingBlockCacheSize, missingBlockTTL := c_missingBlockCacheSize, c_missingBlockRequestTTL
+	if config.MissingBlockCache > 0 {
+		missingBlockCacheSize = config.MissingBlockCache
+	}
+	if config.MissingBlockTTL > 0 {
+		missingBlockTTL = int((config.MissingBlockTTL + time.Second - 1) / time.Second) // Whole seconds, rounded up
+	}
+	missingBlocks, _ := timedcache.New(missingBlockCacheSize, missingBlockTTL)
+	h.missingBlocks = missingBlocks
+
+	if config.Provenance {
+		h.provenance = newProvenanceTracker()
+	}
+	if config.FirstSeenDB != nil {
+		h.firstSeen = newFirstSeenRecorder(config.FirstSeenDB)
+	}
+
+	h.downloader = downloader.New(config.Database, h.eventMux, h.core, h.removePeer)
+	h.setSyncTarget(config.SyncTarget)
+	if config.SyncTimeout != 0 {
+		h.downloader.SetRequestTimeoutLimit(config.SyncTimeout)
+	}
 
 	// Construct the fetcher (short sync)
 	validator := func(header *types.Header) error {
@@ -202,7 +261,13 @@ func newHandler(config *handlerConfig) (*handler, error) {
 		}
 		h.core.WriteBlock(block)
 	}
-	h.blockFetcher = fetcher.NewBlockFetcher(h.core.GetBlockOrCandidateByHash, writeBlock, validator, verifySeal, h.BroadcastBlock, heighter, currentThresholdS, currentS, currentDifficulty, h.removePeer, h.core.IsBlockHashABadHash)
+	broadcast := func(block *types.Block, propagate bool) {
+		h.BroadcastBlock(block, propagate)
+	}
+	h.blockFetcher = fetcher.NewBlockFetcher(h.core.GetBlockOrCandidateByHash, writeBlock, validator, verifySeal, broadcast, heighter, currentThresholdS, currentS, currentDifficulty, h.removePeer, h.core.IsBlockHashABadHash)
+	if config.TipTimeout != 0 {
+		h.blockFetcher.SetFetchTimeout(config.TipTimeout)
+	}
 
 	// Only initialize the Tx fetcher in zone
 	if nodeCtx == common.ZONE_CTX && h.core.ProcessingState() {
@@ -323,6 +388,7 @@ func (h *handler) unregisterPeer(id string) {
 
 func (h *handler) Start(maxPeers int) {
 	h.maxPeers = maxPeers
+	h.firstSeen.start()
 
 	nodeCtx := common.NodeLocation.Context()
 	if nodeCtx == common.ZONE_CTX && h.core.ProcessingState() {
@@ -338,6 +404,11 @@ func (h *handler) Start(maxPeers int) {
 	h.missingBlockSub = h.core.SubscribeMissingBlockEvent(h.missingBlockCh)
 	go h.missingBlockLoop()
 
+	if h.chainCheck {
+		h.wg.Add(1)
+		go h.consistencyLoop()
+	}
+
 	// broadcast mined blocks
 	h.wg.Add(1)
 	h.minedBlockSub = h.eventMux.Subscribe(core.NewMinedBlockEvent{})
@@ -371,16 +442,36 @@ func (h *handler) Stop() {
 	// will exit when they try to register.
 	h.peers.close()
 	h.peerWG.Wait()
+	h.firstSeen.stop()
 
 	log.Info("Quai protocol stopped")
 }
 
+// BlockBroadcastReport describes the delivery of a block broadcast.
+type BlockBroadcastReport struct {
+	Peers     int  // Number of peers connected at the time of the broadcast
+	Queued    int  // Number of peers the block or its hash was queued to (not yet delivered)
+	Announced bool // Whether only the hash of the block was announced
+	Healthy   bool // Whether enough peers were connected for the block to propagate
+}
+
 // BroadcastBlock will either propagate a block to a subset of its peers, or
-// will only announce its availability (depending what's requested).
-func (h *handler) BroadcastBlock(block *types.Block, propagate bool) {
+// will only announce its availability (depending what's requested). The returned
+// report tells how many peers the block was queued to. Peers whose broadcast
+// queue is full are skipped and not counted.
+func (h *handler) BroadcastBlock(block *types.Block, propagate bool) *BlockBroadcastReport {
 	hash := block.Hash()
 	peers := h.peers.peersWithoutBlock(hash)
 
+	report := &BlockBroadcastReport{
+		Peers:     h.peers.len(),
+		Announced: !propagate,
+	}
+	report.Healthy = report.Peers >= minHealthyBroadcastPeers
+	if propagate && !report.Healthy {
+		unhealthyBroadcastMeter.Mark(1)
+	}
+
 	// If propagation is requested, send to a subset of the peer
 	if propagate {
 		// Send the block to a subset of our peers
@@ -392,24 +483,35 @@ func (h *handler) BroadcastBlock(block *types.Block, propagate bool) {
 			peerThreshold = sqrtNumPeers
 		}
 		transfer := peers[:peerThreshold]
+		queued := make([]*ethPeer, 0, len(transfer))
 		for _, peer := range transfer {
 			currentHead := h.core.CurrentHeader()
 			entropy := big.NewInt(0)
 			if currentHead != nil {
 				entropy = h.core.Engine().TotalLogS(h.core.CurrentHeader())
 			}
-			peer.AsyncSendNewBlock(block, entropy)
+			if peer.AsyncSendNewBlock(block, entropy) {
+				queued = append(queued, peer)
+			}
 		}
-		log.Trace("Propagated block", "hash", hash, "recipients", len(transfer), "duration", common.PrettyDuration(time.Since(block.ReceivedAt)))
-		return
+		h.provenance.forwarded(hash, queued, false)
+		report.Queued = len(queued)
+		log.Trace("Propagated block", "hash", hash, "recipients", len(queued), "duration", common.PrettyDuration(time.Since(block.ReceivedAt)))
+		return report
 	}
 	// Otherwise if the block is indeed in out own chain, announce it
 	if h.core.HasBlock(hash, block.NumberU64()) {
+		queued := make([]*ethPeer, 0, len(peers))
 		for _, peer := range peers {
-			peer.AsyncSendNewBlockHash(block)
+			if peer.AsyncSendNewBlockHash(block) {
+				queued = append(queued, peer)
+			}
 		}
-		log.Trace("Announced block", "hash", hash, "recipients", len(peers), "duration", common.PrettyDuration(time.Since(block.ReceivedAt)))
+		h.provenance.forwarded(hash, queued, true)
+		report.Queued = len(queued)
+		log.Trace("Announced block", "hash", hash, "recipients", len(queued), "duration", common.PrettyDuration(time.Since(block.ReceivedAt)))
 	}
+	return report
 }
 
 // BroadcastTransactions will propagate a batch of transactions
@@ -471,8 +573,12 @@ func (h *handler) minedBroadcastLoop() {
 
 	for obj := range h.minedBlockSub.Chan() {
 		if ev, ok := obj.Data.(core.NewMinedBlockEvent); ok {
-			h.BroadcastBlock(ev.Block, true)  // First propagate block to peers
-			h.BroadcastBlock(ev.Block, false) // Only then announce to the rest
+			report := h.BroadcastBlock(ev.Block, true) // First propagate block to peers
+			h.BroadcastBlock(ev.Block, false)          // Only then announce to the rest
+
+			if !report.Healthy {
+				log.Warn("Mined block broadcast to too few peers, it may not propagate", "hash", ev.Block.Hash(), "number", ev.Block.Header().NumberArray(), "peers", report.Peers, "queued", report.Queued)
+			}
 		}
 	}
 }
@@ -490,36 +596,103 @@ func (h *handler) txBroadcastLoop() {
 	}
 }
 
-// missingBlockLoop announces new pendingEtxs to connected peers.
+// missingBlockLoop fetches the missing blocks requested by the core from the
+// connected peers.
 func (h *handler) missingBlockLoop() {
 	defer h.wg.Done()
 	for {
 		select {
 		case blockRequest := <-h.missingBlockCh:
-			headerRequested := 0
-			// Check if any of the peers have the body
-			allPeers := h.peers.allPeers()
-			// shuffle the filteredPeers
-			rand.Shuffle(len(allPeers), func(i, j int) { allPeers[i], allPeers[j] = allPeers[j], allPeers[i] })
-
-			for _, peer := range allPeers {
-				log.Trace("Fetching the missing parent from", "peer", peer.ID(), "hash", blockRequest.Hash)
-				_, _, peerEntropy, _ := peer.Head()
-				if peerEntropy != nil {
-					if peerEntropy.Cmp(blockRequest.Entropy) > 0 {
-						peer.RequestBlockByHash(blockRequest.Hash)
-						headerRequested++
-					}
-				}
-				if headerRequested == minPeerRequest {
-					break
+			// Pick up the requests queued meanwhile too, so that they are fetched
+			// from every peer in a single batch
+			requests := []types.BlockRequest{blockRequest}
+		drain:
+			for len(requests) < missingBlockChanSize {
+				select {
+				case req := <-h.missingBlockCh:
+					requests = append(requests, req)
+				default:
+					break drain
 				}
 			}
-
-			h.subSyncQueue.ContainsOrAdd(blockRequest.Hash, blockRequest)
+			h.requestMissingBlocks(requests, 0, nil)
 
 		case <-h.missingBlockSub.Err():
 			return
 		}
 	}
 }
+
+// requestMissingBlocks asks a few of the peers ahead of every requested block
+// for it, batching all the blocks requested from the same peer. The attempt is
+// the number of times the blocks were already reported missing, and the exclude
+// peer, if any, is not asked.
+func (h *handler) requestMissingBlocks(requests []types.BlockRequest, attempt int, exclude *eth.Peer) {
+	// Check if any of the peers have the body
+	allPeers := h.peers.allPeers()
+	batches := make(map[*eth.Peer][]common.Hash)
+
+	for _, blockRequest := range requests {
+		// If the block was just asked for and still isn't there, the peers
+		// most likely don't have it either, wait for the entry to expire
+		if h.missingBlocks.Contains(blockRequest.Hash) {
+			continue
+		}
+		h.missingBlocks.Add(blockRequest.Hash, attempt)
+
+		headerRequested := 0
+		// shuffle the filteredPeers
+		rand.Shuffle(len(allPeers), func(i, j int) { allPeers[i], allPeers[j] = allPeers[j], allPeers[i] })
+
+		for _, peer := range allPeers {
+			if peer == exclude {
+				continue
+			}
+			log.Trace("Fetching the missing parent from", "peer", peer.ID(), "hash", blockRequest.Hash)
+			_, _, peerEntropy, _ := peer.Head()
+			if peerEntropy != nil {
+				if peerEntropy.Cmp(blockRequest.Entropy) > 0 {
+					batches[peer] = append(batches[peer], blockRequest.Hash)
+					headerRequested++
+				}
+			}
+			if headerRequested == minPeerRequest {
+				break
+			}
+		}
+
+		h.subSyncQueue.ContainsOrAdd(blockRequest.Hash, blockRequest)
+	}
+	for peer, hashes := range batches {
+		if err := peer.RequestBlocksByHash(hashes); err != nil {
+			log.Debug("Failed to request missing blocks", "peer", peer.ID(), "count", len(hashes), "err", err)
+		}
+	}
+}
+
+// retryMissingBlocks requests the blocks a peer reported not to have from other
+// peers right away, instead of waiting for their negative cache entries to
+// expire. Blocks not requested by us, already retrieved, or retried too many
+// times are skipped.
+func (h *handler) retryMissingBlocks(peer *eth.Peer, hashes []common.Hash) {
+	retries := make(map[int][]types.BlockRequest)
+	for _, hash := range hashes {
+		entry, ok := h.subSyncQueue.Peek(hash)
+		if !ok || h.core.GetBlockOrCandidateByHash(hash) != nil {
+			continue
+		}
+		attempt := 0
+		if value, ok := h.missingBlocks.Peek(hash); ok {
+			attempt = value.(int)
+		}
+		if attempt >= c_maxMissingBlockRetries {
+			continue
+		}
+		h.missingBlocks.Remove(hash)
+		retries[attempt+1] = append(retries[attempt+1], entry.(types.BlockRequest))
+	}
+	for attempt, requests := range retries {
+		log.Trace("Retrying blocks missing from peer", "peer", peer.ID(), "count", len(requests), "attempt", attempt)
+		h.requestMissingBlocks(requests, attempt, peer)
+	}
+}
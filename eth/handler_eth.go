@@ -85,13 +85,28 @@ func (h *ethHandler) Handle(peer *eth.Peer, packet eth.Packet) error {
 	case *eth.NewBlockPacket:
 		return h.handleBlockBroadcast(peer, packet.Block, packet.Entropy, packet.Relay)
 
+	case *eth.BlocksPacket:
+		if len(packet.Missing) > 0 {
+			log.Trace("Peer is missing requested blocks", "peer", peer.ID(), "count", len(packet.Missing))
+			go (*handler)(h).retryMissingBlocks(peer, packet.Missing)
+		}
+		for _, block := range packet.Blocks {
+			if err := h.handleBlockBroadcast(peer, block, packet.Entropy, false); err != nil {
+				return err
+			}
+		}
+		return nil
+
 	case *eth.NewPooledTransactionHashesPacket:
+		h.firstSeen.recordTxs(peer.ID(), time.Now(), *packet...)
 		return h.txFetcher.Notify(peer.ID(), *packet)
 
 	case *eth.TransactionsPacket:
+		h.firstSeen.recordTransactions(peer.ID(), time.Now(), *packet)
 		return h.txFetcher.Enqueue(peer.ID(), *packet, false)
 
 	case *eth.PooledTransactionsPacket:
+		h.firstSeen.recordTransactions(peer.ID(), time.Now(), *packet)
 		return h.txFetcher.Enqueue(peer.ID(), *packet, true)
 
 	default:
@@ -155,6 +170,8 @@ func (h *ethHandler) handleBodies(peer *eth.Peer, txs [][]*types.Transaction, un
 // handleBlockAnnounces is invoked from a peer's message handler when it transmits a
 // batch of block announcements for the local node to process.
 func (h *ethHandler) handleBlockAnnounces(peer *eth.Peer, hashes []common.Hash, numbers []uint64) error {
+	h.firstSeen.recordBlocks(peer.ID(), time.Now(), hashes...)
+
 	// Do not handle any broadcast until we finish resetting from the bad state.
 	// This should be a very small time window
 	if h.Core().BadHashExistsInChain() {
@@ -173,6 +190,9 @@ func (h *ethHandler) handleBlockAnnounces(peer *eth.Peer, hashes []common.Hash,
 		}
 	}
 	for i := 0; i < len(unknownHashes); i++ {
+		if (*handler)(h).beyondSyncTarget(unknownNumbers[i]) {
+			continue
+		}
 		h.blockFetcher.Notify(peer.ID(), unknownHashes[i], unknownNumbers[i], time.Now(), peer.RequestOneHeader, peer.RequestBodies)
 	}
 	return nil
@@ -181,12 +201,18 @@ func (h *ethHandler) handleBlockAnnounces(peer *eth.Peer, hashes []common.Hash,
 // handleBlockBroadcast is invoked from a peer's message handler when it transmits a
 // block broadcast for the local node to process.
 func (h *ethHandler) handleBlockBroadcast(peer *eth.Peer, block *types.Block, entropy *big.Int, relay bool) error {
+	h.firstSeen.recordBlocks(peer.ID(), block.ReceivedAt, block.Hash())
+
 	// Do not handle any broadcast until we finish resetting from the bad state.
 	// This should be a very small time window
 	if h.Core().BadHashExistsInChain() {
 		log.Warn("Bad Hashes still exist on chain, cannot handle block broadcast yet")
 		return nil
 	}
+	if (*handler)(h).beyondSyncTarget(block.NumberU64()) {
+		return nil
+	}
+	h.provenance.received(block.Hash(), peer.ID(), block.ReceivedAt)
 
 	syncEntropy, threshold := h.core.SyncTargetEntropy()
 	window := new(big.Int).Mul(threshold, big.NewInt(5))
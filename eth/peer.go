@@ -27,9 +27,10 @@ import (
 // ethPeerInfo represents a short summary of the `eth` sub-protocol metadata known
 // about a connected peer.
 type ethPeerInfo struct {
-	Version uint     `json:"version"` // Quai protocol version negotiated
-	Entropy *big.Int `json:"entropy"` // Head Entropy of the peer's blockchain
-	Head    string   `json:"head"`    // Hex hash of the peer's best owned block
+	Version    uint     `json:"version"`              // Quai protocol version negotiated
+	Entropy    *big.Int `json:"entropy"`              // Head Entropy of the peer's blockchain
+	Head       string   `json:"head"`                 // Hex hash of the peer's best owned block
+	TimeOffset *int64   `json:"timeOffset,omitempty"` // Estimated offset of the peer's clock in milliseconds
 }
 
 // ethPeer is a wrapper around eth.Peer to maintain a few extra metadata.
@@ -44,9 +45,14 @@ type ethPeer struct {
 func (p *ethPeer) info() *ethPeerInfo {
 	hash, _, entropy, _ := p.Head()
 
-	return &ethPeerInfo{
+	info := &ethPeerInfo{
 		Version: p.Version(),
 		Entropy: entropy,
 		Head:    hash.Hex(),
 	}
+	if offset, ok := p.TimeOffset(); ok {
+		ms := offset.Milliseconds()
+		info.TimeOffset = &ms
+	}
+	return info
 }
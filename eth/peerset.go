@@ -19,13 +19,19 @@ package eth
 import (
 	"errors"
 	"math/big"
+	"sort"
 	"sync"
 
 	"github.com/dominant-strategies/go-quai/common"
 	"github.com/dominant-strategies/go-quai/eth/protocols/eth"
+	"github.com/dominant-strategies/go-quai/metrics"
 	"github.com/dominant-strategies/go-quai/p2p"
 )
 
+// timeOffsetGauge tracks the median offset of the peers' clocks from the local
+// one, in milliseconds.
+var timeOffsetGauge = metrics.NewRegisteredGauge("eth/peers/timeoffset", nil)
+
 var (
 	// errPeerSetClosed is returned if a peer is attempted to be added or removed
 	// from the peer set after it has been terminated.
@@ -77,6 +83,7 @@ func (ps *peerSet) registerPeer(peer *eth.Peer) error {
 		Peer: peer,
 	}
 	ps.peers[id] = eth
+	ps.updateTimeOffset()
 	return nil
 }
 
@@ -90,9 +97,27 @@ func (ps *peerSet) unregisterPeer(id string) error {
 		return errPeerNotRegistered
 	}
 	delete(ps.peers, id)
+	ps.updateTimeOffset()
 	return nil
 }
 
+// updateTimeOffset recalculates the median clock offset across the peers that
+// advertised their clock. The caller must hold the lock.
+func (ps *peerSet) updateTimeOffset() {
+	offsets := make([]int64, 0, len(ps.peers))
+	for _, p := range ps.peers {
+		if offset, ok := p.TimeOffset(); ok {
+			offsets = append(offsets, offset.Milliseconds())
+		}
+	}
+	if len(offsets) == 0 {
+		timeOffsetGauge.Update(0)
+		return
+	}
+	sort.Slice(offsets, func(i, j int) bool { return offsets[i] < offsets[j] })
+	timeOffsetGauge.Update(offsets[len(offsets)/2])
+}
+
 // peer retrieves the registered peer with the given id.
 func (ps *peerSet) peer(id string) *ethPeer {
 	ps.lock.RLock()
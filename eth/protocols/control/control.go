@@ -0,0 +1,201 @@
+// Package control implements the `quaictl` protocol, a side channel between
+// nodes of the same operator to exchange small control messages (e.g. checkpoint
+// hashes or coordinated restart notices). Messages travel over the existing
+// RLPx sessions, which are encrypted and authenticated by the node keys, and are
+// only accepted from the allowlisted nodes.
+package control
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/dominant-strategies/go-quai/common/hexutil"
+	"github.com/dominant-strategies/go-quai/log"
+	"github.com/dominant-strategies/go-quai/metrics"
+	"github.com/dominant-strategies/go-quai/p2p"
+	"github.com/dominant-strategies/go-quai/p2p/enode"
+)
+
+const (
+	// ProtocolName is the devp2p capability name of the control protocol.
+	ProtocolName = "quaictl"
+
+	// ProtocolVersion is the version of the control protocol.
+	ProtocolVersion = 1
+
+	// MessageMsg is the code of a control message, the only one in the protocol.
+	MessageMsg = 0x00
+
+	// maxMessageSize is the maximum size of a control message.
+	maxMessageSize = 4 * 1024
+
+	// maxRecentMessages is the number of received messages kept for retrieval.
+	maxRecentMessages = 128
+)
+
+var (
+	errNotAllowed = errors.New("peer is not allowlisted for control messages")
+	errNotPeered  = errors.New("peer is not connected over the control protocol")
+	errTooLarge   = errors.New("control message too large")
+
+	receivedMeter = metrics.NewRegisteredMeter("p2p/control/received", nil)
+	rejectedMeter = metrics.NewRegisteredMeter("p2p/control/rejected", nil)
+)
+
+// Message is the network packet of a control message.
+type Message struct {
+	Topic   string
+	Payload []byte
+}
+
+// Received is a control message received from an allowlisted peer.
+type Received struct {
+	Peer    string        `json:"peer"`
+	Topic   string        `json:"topic"`
+	Payload hexutil.Bytes `json:"payload"`
+	Time    time.Time     `json:"time"`
+}
+
+// Handler runs the control protocol with the connected peers, accepting messages
+// only from the allowlisted ones.
+type Handler struct {
+	rejected uint64 // Number of messages discarded from peers not allowlisted (atomic, kept first for alignment)
+
+	allowed map[enode.ID]struct{}
+	peers   map[enode.ID]p2p.MsgReadWriter // Allowlisted peers running the protocol
+	recent  []Received                     // Most recent received messages, oldest first
+	lock    sync.RWMutex
+}
+
+// NewHandler creates a control protocol handler accepting messages from the
+// given nodes.
+func NewHandler(allowed []enode.ID) *Handler {
+	h := &Handler{
+		allowed: make(map[enode.ID]struct{}, len(allowed)),
+		peers:   make(map[enode.ID]p2p.MsgReadWriter),
+	}
+	for _, id := range allowed {
+		h.allowed[id] = struct{}{}
+	}
+	return h
+}
+
+// Protocols returns the devp2p protocol definition of the control protocol.
+func (h *Handler) Protocols() []p2p.Protocol {
+	return []p2p.Protocol{{
+		Name:    ProtocolName,
+		Version: ProtocolVersion,
+		Length:  1,
+		Run:     h.runPeer,
+	}}
+}
+
+// runPeer serves the control protocol of a single peer until it disconnects.
+// Peers which aren't allowlisted stay connected, since they may still be useful
+// for the other protocols, but their control messages are discarded.
+func (h *Handler) runPeer(peer *p2p.Peer, rw p2p.MsgReadWriter) error {
+	id := peer.ID()
+	_, allowed := h.allowed[id]
+	if allowed {
+		h.lock.Lock()
+		h.peers[id] = rw
+		h.lock.Unlock()
+
+		log.Info("Control channel established", "peer", id)
+		defer func() {
+			h.lock.Lock()
+			delete(h.peers, id)
+			h.lock.Unlock()
+		}()
+	}
+	for {
+		msg, err := rw.ReadMsg()
+		if err != nil {
+			return err
+		}
+		err = h.handleMsg(id, allowed, msg)
+		msg.Discard()
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// handleMsg processes a control message. Anything sent by a peer not on the
+// allowlist is discarded without further checks, so that the peer's session is
+// never torn down over the control protocol.
+func (h *Handler) handleMsg(id enode.ID, allowed bool, msg p2p.Msg) error {
+	if !allowed {
+		atomic.AddUint64(&h.rejected, 1)
+		rejectedMeter.Mark(1)
+		log.Debug("Discarded control message from peer not allowlisted", "peer", id)
+		return nil
+	}
+	if msg.Code != MessageMsg {
+		return fmt.Errorf("invalid control message code %d", msg.Code)
+	}
+	if msg.Size > maxMessageSize {
+		return fmt.Errorf("%w: %v > %v", errTooLarge, msg.Size, maxMessageSize)
+	}
+	var message Message
+	if err := msg.Decode(&message); err != nil {
+		return fmt.Errorf("invalid control message: %v", err)
+	}
+	receivedMeter.Mark(1)
+	log.Info("Received control message", "peer", id, "topic", message.Topic, "size", len(message.Payload))
+
+	h.lock.Lock()
+	defer h.lock.Unlock()
+
+	if len(h.recent) == maxRecentMessages {
+		h.recent = append(h.recent[:0], h.recent[1:]...)
+	}
+	h.recent = append(h.recent, Received{
+		Peer:    id.String(),
+		Topic:   message.Topic,
+		Payload: message.Payload,
+		Time:    time.Now(),
+	})
+	return nil
+}
+
+// Send sends a control message to an allowlisted peer.
+func (h *Handler) Send(id enode.ID, topic string, payload []byte) error {
+	if _, ok := h.allowed[id]; !ok {
+		return errNotAllowed
+	}
+	if len(topic)+len(payload) > maxMessageSize-64 {
+		return errTooLarge // Leave room for the RLP framing
+	}
+	h.lock.RLock()
+	rw, ok := h.peers[id]
+	h.lock.RUnlock()
+	if !ok {
+		return errNotPeered
+	}
+	return p2p.Send(rw, MessageMsg, &Message{Topic: topic, Payload: payload})
+}
+
+// Messages returns the most recent control messages received, oldest first.
+func (h *Handler) Messages() []Received {
+	h.lock.RLock()
+	defer h.lock.RUnlock()
+
+	return append([]Received(nil), h.recent...)
+}
+
+// Peers returns the ids of the allowlisted peers currently connected over the
+// control protocol.
+func (h *Handler) Peers() []string {
+	h.lock.RLock()
+	defer h.lock.RUnlock()
+
+	ids := make([]string, 0, len(h.peers))
+	for id := range h.peers {
+		ids = append(ids, id.String())
+	}
+	return ids
+}
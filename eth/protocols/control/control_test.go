@@ -0,0 +1,119 @@
+package control
+
+import (
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/dominant-strategies/go-quai/p2p"
+	"github.com/dominant-strategies/go-quai/p2p/enode"
+)
+
+// testPeer runs the control protocol of a handler with a simulated remote peer.
+type testPeer struct {
+	app  *p2p.MsgPipeRW // Remote end of the pipe, used to send messages
+	errc chan error     // Termination error of the protocol handler
+}
+
+func newTestPeer(h *Handler, id enode.ID) *testPeer {
+	app, net := p2p.MsgPipe()
+	peer := &testPeer{app: app, errc: make(chan error, 1)}
+	go func() {
+		peer.errc <- h.runPeer(p2p.NewPeer(id, "test", nil), net)
+	}()
+	return peer
+}
+
+func (p *testPeer) close() {
+	p.app.Close()
+}
+
+// waitMessages waits until the handler has recorded the given number of messages.
+func waitMessages(t *testing.T, h *Handler, n int) []Received {
+	t.Helper()
+	for deadline := time.Now().Add(time.Second); time.Now().Before(deadline); time.Sleep(5 * time.Millisecond) {
+		if msgs := h.Messages(); len(msgs) >= n {
+			return msgs
+		}
+	}
+	t.Fatalf("timed out waiting for %d messages, have %d", n, len(h.Messages()))
+	return nil
+}
+
+// Tests that the messages of allowlisted peers are recorded.
+func TestAllowedMessages(t *testing.T) {
+	id := enode.ID{1}
+	h := NewHandler([]enode.ID{id})
+	peer := newTestPeer(h, id)
+	defer peer.close()
+
+	if err := p2p.Send(peer.app, MessageMsg, &Message{Topic: "checkpoint", Payload: []byte{0x01, 0x02}}); err != nil {
+		t.Fatalf("failed to send message: %v", err)
+	}
+	msgs := waitMessages(t, h, 1)
+	if msgs[0].Peer != id.String() || msgs[0].Topic != "checkpoint" || string(msgs[0].Payload) != "\x01\x02" {
+		t.Errorf("recorded message mismatch: have %+v", msgs[0])
+	}
+	if peers := h.Peers(); len(peers) != 1 || peers[0] != id.String() {
+		t.Errorf("control peers mismatch: have %v, want [%v]", peers, id)
+	}
+}
+
+// Tests that the messages of peers not on the allowlist are discarded and
+// counted, without disconnecting the peers, even when they are too large.
+func TestDiscardedMessages(t *testing.T) {
+	allowed, other := enode.ID{1}, enode.ID{2}
+	h := NewHandler([]enode.ID{allowed})
+	peer := newTestPeer(h, other)
+	defer peer.close()
+
+	if err := p2p.Send(peer.app, MessageMsg, &Message{Topic: "restart"}); err != nil {
+		t.Fatalf("failed to send message: %v", err)
+	}
+	if err := p2p.Send(peer.app, MessageMsg, &Message{Topic: "restart", Payload: make([]byte, 2*maxMessageSize)}); err != nil {
+		t.Fatalf("failed to send oversized message: %v", err)
+	}
+	select {
+	case err := <-peer.errc:
+		t.Fatalf("peer disconnected: %v", err)
+	case <-time.After(50 * time.Millisecond):
+	}
+	if n := atomic.LoadUint64(&h.rejected); n != 2 {
+		t.Errorf("rejected messages mismatch: have %d, want 2", n)
+	}
+	if msgs := h.Messages(); len(msgs) != 0 {
+		t.Errorf("discarded messages recorded: %v", msgs)
+	}
+	if peers := h.Peers(); len(peers) != 0 {
+		t.Errorf("peer not allowlisted tracked: %v", peers)
+	}
+	if err := h.Send(other, "topic", nil); err != errNotAllowed {
+		t.Errorf("send to peer not allowlisted: have %v, want %v", err, errNotAllowed)
+	}
+}
+
+// Tests that an oversized message from an allowlisted peer is rejected.
+func TestOversizedMessage(t *testing.T) {
+	id := enode.ID{1}
+	h := NewHandler([]enode.ID{id})
+	peer := newTestPeer(h, id)
+	defer peer.close()
+
+	go p2p.Send(peer.app, MessageMsg, &Message{Topic: "checkpoint", Payload: make([]byte, 2*maxMessageSize)})
+
+	select {
+	case err := <-peer.errc:
+		if !errors.Is(err, errTooLarge) {
+			t.Errorf("termination error mismatch: have %v, want %v", err, errTooLarge)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("peer not disconnected after an oversized message")
+	}
+	if msgs := h.Messages(); len(msgs) != 0 {
+		t.Errorf("oversized message recorded: %v", msgs)
+	}
+	if err := h.Send(id, "topic", make([]byte, maxMessageSize)); err != errTooLarge {
+		t.Errorf("oversized send: have %v, want %v", err, errTooLarge)
+	}
+}
@@ -53,6 +53,11 @@ const (
 	// containing 200+ transactions nowadays, the practical limit will always
 	// be softResponseLimit.
 	maxReceiptsServe = 1024
+
+	// maxBlocksServe is the maximum number of full blocks to serve in a single
+	// batch. Blocks are much larger than headers or bodies alone, so the limit is
+	// kept well below the others.
+	maxBlocksServe = 128
 )
 
 // Handler is a callback to invoke from an outside runner after the boilerplate
@@ -190,9 +195,25 @@ var quai1 = map[uint64]msgHandler{
 	GetBlockMsg:              handleGetBlock66,
 }
 
+var quai4 = map[uint64]msgHandler{
+	NewBlockHashesMsg:             handleNewBlockhashes,
+	NewBlockMsg:                   handleNewBlock,
+	TransactionsMsg:               handleTransactions,
+	NewPooledTransactionHashesMsg: handleNewPooledTransactionHashes,
+	GetBlockHeadersMsg:            handleGetBlockHeaders66,
+	BlockHeadersMsg:               handleBlockHeaders66,
+	GetBlockBodiesMsg:             handleGetBlockBodies66,
+	BlockBodiesMsg:                handleBlockBodies66,
+	GetPooledTransactionsMsg:      handleGetPooledTransactions66,
+	PooledTransactionsMsg:         handlePooledTransactions66,
+	GetBlockMsg:                   handleGetBlock66,
+	GetBlocksMsg:                  handleGetBlocks66,
+	BlocksMsg:                     handleBlocks66,
+}
+
 // handleMessage is invoked whenever an inbound message is received from a remote
 // peer. The remote connection is torn down upon returning any error.
-func handleMessage(backend Backend, peer *Peer) error {
+func handleMessage(backend Backend, peer *Peer) (err error) {
 	// Read the next message from the remote peer, and ensure it's fully consumed
 	msg, err := peer.rw.ReadMsg()
 	if err != nil {
@@ -214,7 +235,9 @@ func handleMessage(backend Backend, peer *Peer) error {
 		}
 	}
 	// If below the fork block number retain the same behavior
-	if peer.Version() >= QUAI1 {
+	if peer.Version() >= QUAI4 {
+		handlers = quai4
+	} else if peer.Version() >= QUAI1 {
 		handlers = quai1
 	} else {
 		return fmt.Errorf("protocol version not supported")
@@ -232,6 +255,16 @@ func handleMessage(backend Backend, peer *Peer) error {
 			metrics.GetOrRegisterHistogramLazy(h, nil, sampler).Update(time.Since(start).Microseconds())
 		}(time.Now())
 	}
+	// Track the load of serving requests, so deep history ones can be shed
+	if isRequestMsg(msg.Code) {
+		defer startServing()()
+	}
+	// Log a sample of the served requests if enabled
+	if isRequestMsg(msg.Code) && sampleRequest() {
+		defer func(start time.Time) {
+			logRequest("inbound", peer, msg.Code, time.Since(start), err)
+		}(time.Now())
+	}
 	if handler := handlers[msg.Code]; handler != nil {
 		return handler(backend, msg, peer)
 	}
@@ -35,6 +35,11 @@ func handleGetBlockHeaders66(backend Backend, msg Decoder, peer *Peer) error {
 	if err := msg.Decode(&query); err != nil {
 		return fmt.Errorf("%w: message %v: %v", errDecode, msg, err)
 	}
+	if overloaded() && deepHistory(backend, headersQueryOrigin(backend, query.GetBlockHeadersPacket)) {
+		shedRequestsMeter.Mark(1)
+		peer.Log().Trace("Shedding deep header request", "origin", query.Origin)
+		return peer.ReplyBlockHeaders(query.RequestId, nil)
+	}
 	response := answerGetBlockHeadersQuery(backend, query.GetBlockHeadersPacket, peer)
 	return peer.ReplyBlockHeaders(query.RequestId, response)
 }
@@ -109,12 +114,25 @@ func answerGetBlockHeadersQuery(backend Backend, query *GetBlockHeadersPacket, p
 	return headers
 }
 
+// headersQueryOrigin returns the number of the block a header query starts at.
+func headersQueryOrigin(backend Backend, query *GetBlockHeadersPacket) uint64 {
+	if query.Origin.Hash != (common.Hash{}) {
+		return headerNumber(backend, query.Origin.Hash)
+	}
+	return query.Origin.Number
+}
+
 func handleGetBlockBodies66(backend Backend, msg Decoder, peer *Peer) error {
 	// Decode the block body retrieval message
 	var query GetBlockBodiesPacket66
 	if err := msg.Decode(&query); err != nil {
 		return fmt.Errorf("%w: message %v: %v", errDecode, msg, err)
 	}
+	if len(query.GetBlockBodiesPacket) > 0 && overloaded() && deepHistory(backend, headerNumber(backend, query.GetBlockBodiesPacket[0])) {
+		shedRequestsMeter.Mark(1)
+		peer.Log().Trace("Shedding deep body request", "count", len(query.GetBlockBodiesPacket))
+		return peer.ReplyBlockBodiesRLP(query.RequestId, nil)
+	}
 	response := answerGetBlockBodiesQuery(backend, query.GetBlockBodiesPacket, peer)
 	return peer.ReplyBlockBodiesRLP(query.RequestId, response)
 }
@@ -158,6 +176,46 @@ func handleGetBlock66(backend Backend, msg Decoder, peer *Peer) error {
 	return nil
 }
 
+func handleGetBlocks66(backend Backend, msg Decoder, peer *Peer) error {
+	// Decode the batched block retrieval message
+	var query GetBlocksPacket66
+	if err := msg.Decode(&query); err != nil {
+		return fmt.Errorf("%w: message %v: %v", errDecode, msg, err)
+	}
+	blocks, missing := answerGetBlocksQuery(backend.Core().GetBlockOrCandidateByHash, query.GetBlocksPacket)
+
+	entropy := big.NewInt(0)
+	if currentHead := backend.Core().CurrentHeader(); currentHead != nil {
+		entropy = backend.Core().Engine().TotalLogS(currentHead)
+	}
+	return peer.ReplyBlocks(query.RequestId, blocks, missing, entropy)
+}
+
+// answerGetBlocksQuery gathers the requested blocks, along with the hashes of the
+// blocks that are unknown or didn't fit into the response limits, so that the
+// requester can ask them from someone else right away.
+func answerGetBlocksQuery(getBlock func(common.Hash) *types.Block, query GetBlocksPacket) ([]*types.Block, []common.Hash) {
+	var (
+		bytes   common.StorageSize
+		blocks  []*types.Block
+		missing []common.Hash
+	)
+	for _, hash := range query {
+		if bytes >= softResponseLimit || len(blocks) >= maxBlocksServe {
+			missing = append(missing, hash)
+			continue
+		}
+		block := getBlock(hash)
+		if block == nil {
+			missing = append(missing, hash)
+			continue
+		}
+		blocks = append(blocks, block)
+		bytes += block.Size()
+	}
+	return blocks, missing
+}
+
 func handleNewBlockhashes(backend Backend, msg Decoder, peer *Peer) error {
 	// A batch of new block announcements just arrived
 	ann := new(NewBlockHashesPacket)
@@ -173,7 +231,6 @@ func handleNewBlockhashes(backend Backend, msg Decoder, peer *Peer) error {
 }
 
 func handleNewBlock(backend Backend, msg Decoder, peer *Peer) error {
-	nodeCtx := common.NodeLocation.Context()
 	// Retrieve and decode the propagated block
 	ann := new(NewBlockPacket)
 	if err := msg.Decode(ann); err != nil {
@@ -182,43 +239,53 @@ func handleNewBlock(backend Backend, msg Decoder, peer *Peer) error {
 	if err := ann.sanityCheck(); err != nil {
 		return err
 	}
+	if !validBlockBody(ann.Block, peer) {
+		return nil // TODO: return error eventually, but wait a few releases
+	}
+	ann.Block.ReceivedAt = msg.Time()
+	ann.Block.ReceivedFrom = peer
+
+	// Mark the peer as owning the block
+	peer.markBlock(ann.Block.Hash())
+
+	return backend.Handle(peer, ann)
+}
+
+// validBlockBody checks that the body of a propagated or served block matches
+// the commitments in its header, logging the reason if it doesn't.
+func validBlockBody(block *types.Block, peer *Peer) bool {
+	nodeCtx := common.NodeLocation.Context()
 	// Making sure that the region and prime chains have zero txs and etxs in them
 	if nodeCtx == common.ZONE_CTX {
-		if hash := types.CalcUncleHash(ann.Block.Uncles()); hash != ann.Block.UncleHash() {
-			log.Warn("Propagated block has invalid uncles", "have", hash, "exp", ann.Block.UncleHash())
-			return nil // TODO: return error eventually, but wait a few releases
+		if hash := types.CalcUncleHash(block.Uncles()); hash != block.UncleHash() {
+			log.Warn("Propagated block has invalid uncles", "have", hash, "exp", block.UncleHash())
+			return false
 		}
-		if hash := types.DeriveSha(ann.Block.Transactions(), trie.NewStackTrie(nil)); hash != ann.Block.TxHash() {
-			log.Warn("Propagated block has invalid transaction", "have", hash, "exp", ann.Block.TxHash())
-			return nil // TODO: return error eventually, but wait a few releases
+		if hash := types.DeriveSha(block.Transactions(), trie.NewStackTrie(nil)); hash != block.TxHash() {
+			log.Warn("Propagated block has invalid transaction", "have", hash, "exp", block.TxHash())
+			return false
 		}
-		if hash := types.DeriveSha(ann.Block.ExtTransactions(), trie.NewStackTrie(nil)); hash != ann.Block.EtxHash() {
-			log.Warn("Propagated block has invalid external transaction", "have", hash, "exp", ann.Block.EtxHash())
-			return nil // TODO: return error eventually, but wait a few releases
+		if hash := types.DeriveSha(block.ExtTransactions(), trie.NewStackTrie(nil)); hash != block.EtxHash() {
+			log.Warn("Propagated block has invalid external transaction", "have", hash, "exp", block.EtxHash())
+			return false
 		}
 	} else {
-		if len(ann.Block.Transactions()) != 0 {
-			log.Warn("Propagated block has transactions in the body", "len", len(ann.Block.Transactions()))
+		if len(block.Transactions()) != 0 {
+			log.Warn("Propagated block has transactions in the body", "len", len(block.Transactions()))
 		}
-		if len(ann.Block.ExtTransactions()) != 0 {
-			log.Warn("Propagated block has ext transactions in the body", "len", len(ann.Block.ExtTransactions()))
+		if len(block.ExtTransactions()) != 0 {
+			log.Warn("Propagated block has ext transactions in the body", "len", len(block.ExtTransactions()))
 		}
-		if len(ann.Block.Uncles()) != 0 {
-			log.Warn("Propagated block has uncles in the body", "len", len(ann.Block.Uncles()))
+		if len(block.Uncles()) != 0 {
+			log.Warn("Propagated block has uncles in the body", "len", len(block.Uncles()))
 		}
 		// Dom nodes need to validate the subordinate manifest against the subordinate's manifesthash
-		if hash := types.DeriveSha(ann.Block.SubManifest(), trie.NewStackTrie(nil)); hash != ann.Block.ManifestHash(nodeCtx+1) {
-			log.Warn("Propagated block has invalid subordinate manifest", "peer", peer.id, "block hash", ann.Block.Hash(), "have", hash, "exp", ann.Block.ManifestHash())
-			return nil
+		if hash := types.DeriveSha(block.SubManifest(), trie.NewStackTrie(nil)); hash != block.ManifestHash(nodeCtx+1) {
+			log.Warn("Propagated block has invalid subordinate manifest", "peer", peer.id, "block hash", block.Hash(), "have", hash, "exp", block.ManifestHash())
+			return false
 		}
 	}
-	ann.Block.ReceivedAt = msg.Time()
-	ann.Block.ReceivedFrom = peer
-
-	// Mark the peer as owning the block
-	peer.markBlock(ann.Block.Hash())
-
-	return backend.Handle(peer, ann)
+	return true
 }
 
 func handleBlockHeaders66(backend Backend, msg Decoder, peer *Peer) error {
@@ -227,7 +294,9 @@ func handleBlockHeaders66(backend Backend, msg Decoder, peer *Peer) error {
 	if err := msg.Decode(res); err != nil {
 		return fmt.Errorf("%w: message %v: %v", errDecode, msg, err)
 	}
-	requestTracker.Fulfil(peer.id, peer.version, BlockHeadersMsg, res.RequestId)
+	if ok, err := fulfilRequest(peer, BlockHeadersMsg, res.RequestId); !ok {
+		return err
+	}
 
 	return backend.Handle(peer, &res.BlockHeadersPacket)
 }
@@ -238,11 +307,46 @@ func handleBlockBodies66(backend Backend, msg Decoder, peer *Peer) error {
 	if err := msg.Decode(res); err != nil {
 		return fmt.Errorf("%w: message %v: %v", errDecode, msg, err)
 	}
-	requestTracker.Fulfil(peer.id, peer.version, BlockBodiesMsg, res.RequestId)
+	if ok, err := fulfilRequest(peer, BlockBodiesMsg, res.RequestId); !ok {
+		return err
+	}
 
 	return backend.Handle(peer, &res.BlockBodiesPacket)
 }
 
+func handleBlocks66(backend Backend, msg Decoder, peer *Peer) error {
+	// A batch of blocks arrived to one of our previous requests
+	res := new(BlocksPacket66)
+	if err := msg.Decode(res); err != nil {
+		return fmt.Errorf("%w: message %v: %v", errDecode, msg, err)
+	}
+	if ok, err := fulfilRequest(peer, BlocksMsg, res.RequestId); !ok {
+		return err
+	}
+	// Drop the blocks failing validation, and hand the rest over as if they were
+	// individually served
+	blocks := res.Blocks[:0]
+	for i, block := range res.Blocks {
+		if block == nil {
+			return fmt.Errorf("%w: block %d is nil", errDecode, i)
+		}
+		if err := block.SanityCheck(); err != nil {
+			return err
+		}
+		if !validBlockBody(block, peer) {
+			continue
+		}
+		block.ReceivedAt = msg.Time()
+		block.ReceivedFrom = peer
+
+		peer.markBlock(block.Hash())
+		blocks = append(blocks, block)
+	}
+	res.Blocks = blocks
+
+	return backend.Handle(peer, &res.BlocksPacket)
+}
+
 func handleNewPooledTransactionHashes(backend Backend, msg Decoder, peer *Peer) error {
 	nodeCtx := common.NodeLocation.Context()
 	if nodeCtx != common.ZONE_CTX {
@@ -356,7 +460,30 @@ func handlePooledTransactions66(backend Backend, msg Decoder, peer *Peer) error
 		}
 		peer.markTransaction(tx.Hash())
 	}
-	requestTracker.Fulfil(peer.id, peer.version, PooledTransactionsMsg, txs.RequestId)
+	if ok, err := fulfilRequest(peer, PooledTransactionsMsg, txs.RequestId); !ok {
+		return err
+	}
 
 	return backend.Handle(peer, &txs.PooledTransactionsPacket)
 }
+
+// fulfilRequest matches a response against the peer's pending requests. If the
+// response was not asked for, false is returned and the caller should drop the
+// response. Once a peer sent too many unsolicited responses within a short time,
+// an error is also returned, to be handed back to the protocol handler to
+// disconnect the peer.
+func fulfilRequest(peer *Peer, code uint64, id uint64) (bool, error) {
+	elapsed, err := peer.tracker.Fulfil(peer.id, peer.version, code, id)
+	if sampleRequest() {
+		logRequest("outbound", peer, code, elapsed, err)
+	}
+	if err == nil {
+		return true, nil
+	}
+	peer.Log().Debug("Dropping unexpected response", "code", code, "id", id, "err", err)
+	if offenses := peer.markUnsolicited(); offenses >= maxUnsolicitedResponses {
+		peer.Log().Warn("Disconnecting peer sending unsolicited responses", "offenses", offenses)
+		return false, errUnsolicitedResponses
+	}
+	return false, nil
+}
@@ -0,0 +1,86 @@
+// Copyright 2023 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package eth
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/dominant-strategies/go-quai/common"
+	"github.com/dominant-strategies/go-quai/core/types"
+)
+
+// makeBlocks creates the given number of distinct blocks, each padded to about
+// the given size, indexed by their hash.
+func makeBlocks(n int, size int) ([]common.Hash, map[common.Hash]*types.Block) {
+	hashes := make([]common.Hash, n)
+	blocks := make(map[common.Hash]*types.Block, n)
+	for i := 0; i < n; i++ {
+		header := types.EmptyHeader()
+		header.SetNumber(big.NewInt(int64(i + 1)))
+		header.SetExtra(make([]byte, size))
+		block := types.NewBlockWithHeader(header)
+		hashes[i] = block.Hash()
+		blocks[block.Hash()] = block
+	}
+	return hashes, blocks
+}
+
+// Tests that a batched block request is answered with the known blocks, and that
+// the unknown ones and those beyond the response limits are reported missing, in
+// request order.
+func TestAnswerGetBlocksQuery(t *testing.T) {
+	tests := []struct {
+		name    string
+		count   int // Number of known blocks requested
+		size    int // Padding of every block
+		unknown int // Number of unknown blocks requested after the known ones
+		served  int // Expected number of blocks served
+	}{
+		{name: "all known", count: 10, size: 0, served: 10},
+		{name: "some unknown", count: 10, size: 0, unknown: 5, served: 10},
+		{name: "count limit", count: maxBlocksServe + 10, size: 0, unknown: 1, served: maxBlocksServe},
+		{name: "size limit", count: 40, size: 256 * 1024, served: softResponseLimit / (256 * 1024)},
+	}
+	for _, tt := range tests {
+		hashes, blocks := makeBlocks(tt.count, tt.size)
+		for i := 0; i < tt.unknown; i++ {
+			hashes = append(hashes, common.BytesToHash([]byte{0xff, byte(i)}))
+		}
+		getBlock := func(hash common.Hash) *types.Block { return blocks[hash] }
+
+		served, missing := answerGetBlocksQuery(getBlock, GetBlocksPacket(hashes))
+		if len(served) != tt.served {
+			t.Errorf("%s: served blocks mismatch: have %d, want %d", tt.name, len(served), tt.served)
+			continue
+		}
+		if len(served)+len(missing) != len(hashes) {
+			t.Errorf("%s: served and missing don't cover the request: have %d+%d, want %d", tt.name, len(served), len(missing), len(hashes))
+			continue
+		}
+		for i, block := range served {
+			if block.Hash() != hashes[i] {
+				t.Errorf("%s: served block %d mismatch: have %x, want %x", tt.name, i, block.Hash(), hashes[i])
+			}
+		}
+		for i, hash := range missing {
+			if want := hashes[len(served)+i]; hash != want {
+				t.Errorf("%s: missing hash %d mismatch: have %x, want %x", tt.name, i, hash, want)
+			}
+		}
+	}
+}
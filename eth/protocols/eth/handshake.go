@@ -41,6 +41,11 @@ func (p *Peer) Handshake(network uint64, slices []common.Location, entropy *big.
 	var status StatusPacket // safe to read after two values have been received from errc
 
 	go func() {
+		// Peers before quai/104 can't decode the clock, leave it out for them
+		var now uint64
+		if p.version >= QUAI3 {
+			now = uint64(time.Now().UnixMilli())
+		}
 		errc <- p2p.Send(p.rw, StatusMsg, &StatusPacket{
 			ProtocolVersion: uint32(p.version),
 			NetworkID:       network,
@@ -50,6 +55,7 @@ func (p *Peer) Handshake(network uint64, slices []common.Location, entropy *big.
 			Head:            head,
 			Genesis:         genesis,
 			ForkID:          forkID,
+			Time:            now,
 		})
 	}()
 	go func() {
@@ -108,5 +114,11 @@ func (p *Peer) readStatus(network uint64, status *StatusPacket, genesis common.H
 	if len(status.SlicesRunning) == 0 || len(status.SlicesRunning) > common.NumRegionsInPrime*common.NumZonesInRegion {
 		return fmt.Errorf("%w: %v", errSlicesRunningRejected, fmt.Errorf("slices running sanity check failed"))
 	}
+	// Estimate the offset of the remote clock. The estimate is biased by the
+	// one way latency of the link, which is negligible for timestamp checks.
+	if status.Time != 0 {
+		p.timeOffset = time.Duration(int64(status.Time)-msg.ReceivedAt.UnixMilli()) * time.Millisecond
+		p.timeKnown = true
+	}
 	return nil
 }
@@ -0,0 +1,94 @@
+// Copyright 2023 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package eth
+
+import (
+	"sync/atomic"
+	"time"
+
+	"github.com/dominant-strategies/go-quai/common"
+	"github.com/dominant-strategies/go-quai/metrics"
+)
+
+const (
+	// shedInflightLimit is the number of requests served concurrently across all
+	// peers above which the node is considered to be overloaded.
+	shedInflightLimit = 32
+
+	// shedLatencyLimit is the smoothed request serving time above which the node
+	// is considered to be overloaded.
+	shedLatencyLimit = 500 * time.Millisecond
+
+	// shedHistoryDepth is the distance behind the current head past which a
+	// request is considered to be for deep history, and may be shed.
+	shedHistoryDepth = 1024
+
+	// shedLatencyWeight is the weight of a new measurement in the smoothed
+	// request serving time.
+	shedLatencyWeight = 0.1
+)
+
+var (
+	servingRequests int64 // Number of requests currently being served (atomic)
+	servingLatency  int64 // Smoothed request serving time in nanoseconds (atomic)
+
+	shedRequestsMeter = metrics.NewRegisteredMeter("eth/protocols/eth/requests/shed", nil)
+)
+
+// startServing marks the start of serving a remote request and returns the
+// callback to invoke once the reply has been sent.
+func startServing() func() {
+	atomic.AddInt64(&servingRequests, 1)
+	start := time.Now()
+
+	return func() {
+		atomic.AddInt64(&servingRequests, -1)
+
+		elapsed := float64(time.Since(start))
+		for {
+			old := atomic.LoadInt64(&servingLatency)
+			smoothed := int64((1-shedLatencyWeight)*float64(old) + shedLatencyWeight*elapsed)
+			if atomic.CompareAndSwapInt64(&servingLatency, old, smoothed) {
+				return
+			}
+		}
+	}
+}
+
+// overloaded reports whether the node is serving more requests, or serving them
+// slower, than it can sustain without delaying block and transaction gossip.
+func overloaded() bool {
+	return atomic.LoadInt64(&servingRequests) > shedInflightLimit ||
+		time.Duration(atomic.LoadInt64(&servingLatency)) > shedLatencyLimit
+}
+
+// deepHistory reports whether a request reaching back to the given block number
+// is for deep history. While the node is overloaded such requests are answered
+// with an empty response instead of being served, so that peers following the
+// chain tip keep being served.
+func deepHistory(backend Backend, number uint64) bool {
+	return number+shedHistoryDepth < backend.Core().CurrentHeader().NumberU64()
+}
+
+// headerNumber returns the number of the block with the given hash, or zero if
+// it is not known.
+func headerNumber(backend Backend, hash common.Hash) uint64 {
+	if header := backend.Core().GetHeaderOrCandidateByHash(hash); header != nil {
+		return header.NumberU64()
+	}
+	return 0
+}
@@ -17,8 +17,8 @@
 package eth
 
 import (
+	"errors"
 	"math/big"
-	"math/rand"
 	"sync"
 	"time"
 
@@ -26,6 +26,7 @@ import (
 	"github.com/dominant-strategies/go-quai/common"
 	"github.com/dominant-strategies/go-quai/core/types"
 	"github.com/dominant-strategies/go-quai/p2p"
+	"github.com/dominant-strategies/go-quai/p2p/tracker"
 	"github.com/dominant-strategies/go-quai/rlp"
 )
 
@@ -55,6 +56,16 @@ const (
 	// dropping broadcasts. Similarly to block propagations, there's no point to queue
 	// above some healthy uncle limit, so use that.
 	maxQueuedBlockAnns = 4
+
+	// maxUnsolicitedResponses is the number of responses to requests that were
+	// never made, or that already expired, received within the window after
+	// which the peer is disconnected.
+	maxUnsolicitedResponses = 16
+
+	// unsolicitedResponseWindow is the period over which unsolicited responses
+	// are counted, so that the occasional late answer of a long lived peer never
+	// adds up to a disconnection.
+	unsolicitedResponseWindow = 10 * time.Minute
 )
 
 // max is a helper function which returns the larger of the two given integers.
@@ -79,6 +90,9 @@ type Peer struct {
 	entropy        *big.Int    // Latest advertised head block entropy
 	receivedHeadAt time.Time   // Time when the head was received
 
+	timeOffset time.Duration // Estimated offset of the peer's clock from ours
+	timeKnown  bool          // Whether the peer advertised its clock
+
 	knownBlocks     mapset.Set             // Set of block hashes known to be known by this peer
 	queuedBlocks    chan *blockPropagation // Queue of blocks to broadcast to the peer
 	queuedBlockAnns chan *types.Block      // Queue of blocks to announce to the peer
@@ -88,6 +102,11 @@ type Peer struct {
 	txBroadcast chan []common.Hash // Channel used to queue transaction propagation requests
 	txAnnounce  chan []common.Hash // Channel used to queue transaction announcement requests
 
+	tracker          *tracker.Tracker // Pending request table for the requests sent to this peer
+	reqIDs           *requestIDs      // Generator of the ids of the requests sent to this peer
+	unsolicited      int              // Number of unsolicited responses received in the current window
+	unsolicitedSince time.Time        // Start of the current window of unsolicited responses
+
 	term chan struct{} // Termination channel to stop the broadcasters
 	lock sync.RWMutex  // Mutex protecting the internal fields
 }
@@ -107,6 +126,8 @@ func NewPeer(version uint, p *p2p.Peer, rw p2p.MsgReadWriter, txpool TxPool) *Pe
 		txBroadcast:     make(chan []common.Hash),
 		txAnnounce:      make(chan []common.Hash),
 		txpool:          txpool,
+		tracker:         newRequestTracker(),
+		reqIDs:          newRequestIDs(),
 		term:            make(chan struct{}),
 	}
 	// Start up all the broadcasters
@@ -118,11 +139,27 @@ func NewPeer(version uint, p *p2p.Peer, rw p2p.MsgReadWriter, txpool TxPool) *Pe
 	return peer
 }
 
+// markUnsolicited records an unsolicited response from the peer, returning the
+// number received within the current window. The count starts over once the
+// window opened by the first counted response has passed.
+func (p *Peer) markUnsolicited() int {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	now := time.Now()
+	if now.Sub(p.unsolicitedSince) > unsolicitedResponseWindow {
+		p.unsolicited, p.unsolicitedSince = 0, now
+	}
+	p.unsolicited++
+	return p.unsolicited
+}
+
 // Close signals the broadcast goroutine to terminate. Only ever call this if
 // you created the peer yourself via NewPeer. Otherwise let whoever created it
 // clean it up!
 func (p *Peer) Close() {
 	close(p.term)
+	p.tracker.Stop()
 }
 
 // ID retrieves the peer's unique identifier.
@@ -155,6 +192,12 @@ func (p *Peer) SetHead(hash common.Hash, number *big.Int, entropy *big.Int, rece
 	p.entropy = new(big.Int).Set(entropy)
 }
 
+// TimeOffset returns the estimated offset of the peer's clock from the local
+// one, and whether the peer advertised its clock in the handshake.
+func (p *Peer) TimeOffset() (time.Duration, bool) {
+	return p.timeOffset, p.timeKnown
+}
+
 // SlicesRunning returns the slices that are running by the node
 func (p *Peer) SlicesRunning() []common.Location {
 	return p.slicesRunning
@@ -314,9 +357,9 @@ func (p *Peer) SendNewBlockHashes(hashes []common.Hash, numbers []uint64) error
 }
 
 // AsyncSendNewBlockHash queues the availability of a block for propagation to a
-// remote peer. If the peer's broadcast queue is full, the event is silently
-// dropped.
-func (p *Peer) AsyncSendNewBlockHash(block *types.Block) {
+// remote peer, returning whether it was queued. If the peer's broadcast queue is
+// full, the event is dropped.
+func (p *Peer) AsyncSendNewBlockHash(block *types.Block) bool {
 	select {
 	case p.queuedBlockAnns <- block:
 		// Mark all the block hash as known, but ensure we don't overflow our limits
@@ -324,8 +367,10 @@ func (p *Peer) AsyncSendNewBlockHash(block *types.Block) {
 			p.knownBlocks.Pop()
 		}
 		p.knownBlocks.Add(block.Hash())
+		return true
 	default:
 		p.Log().Debug("Dropping block announcement", "number", block.NumberU64(), "hash", block.Hash())
+		return false
 	}
 }
 
@@ -343,9 +388,10 @@ func (p *Peer) SendNewBlock(block *types.Block, entropy *big.Int, relay bool) er
 	})
 }
 
-// AsyncSendNewBlock queues an entire block for propagation to a remote peer. If
-// the peer's broadcast queue is full, the event is silently dropped.
-func (p *Peer) AsyncSendNewBlock(block *types.Block, entropy *big.Int) {
+// AsyncSendNewBlock queues an entire block for propagation to a remote peer,
+// returning whether it was queued. If the peer's broadcast queue is full, the
+// event is dropped.
+func (p *Peer) AsyncSendNewBlock(block *types.Block, entropy *big.Int) bool {
 	select {
 	case p.queuedBlocks <- &blockPropagation{block: block, entropy: entropy}:
 		// Mark all the block hash as known, but ensure we don't overflow our limits
@@ -353,8 +399,10 @@ func (p *Peer) AsyncSendNewBlock(block *types.Block, entropy *big.Int) {
 			p.knownBlocks.Pop()
 		}
 		p.knownBlocks.Add(block.Hash())
+		return true
 	default:
 		p.Log().Debug("Dropping block propagation", "number", block.NumberU64(), "hash", block.Hash())
+		return false
 	}
 }
 
@@ -386,6 +434,26 @@ func (p *Peer) ReplyBlockBodiesRLP(id uint64, bodies []rlp.RawValue) error {
 	})
 }
 
+// ReplyBlocks sends a batch of requested blocks to the remote peer, along with
+// the hashes of the requested blocks that could not be served.
+func (p *Peer) ReplyBlocks(id uint64, blocks []*types.Block, missing []common.Hash, entropy *big.Int) error {
+	// Mark all the blocks as known, but ensure we don't overflow our limits
+	for p.knownBlocks.Cardinality() > max(0, maxKnownBlocks-len(blocks)) {
+		p.knownBlocks.Pop()
+	}
+	for _, block := range blocks {
+		p.knownBlocks.Add(block.Hash())
+	}
+	return p2p.Send(p.rw, BlocksMsg, &BlocksPacket66{
+		RequestId: id,
+		BlocksPacket: BlocksPacket{
+			Blocks:  blocks,
+			Missing: missing,
+			Entropy: entropy,
+		},
+	})
+}
+
 // RequestOneHeader is a wrapper around the header query functions to fetch a
 // single header. It is used solely by the fetcher.
 func (p *Peer) RequestOneHeader(hash common.Hash) error {
@@ -397,12 +465,11 @@ func (p *Peer) RequestOneHeader(hash common.Hash) error {
 		Reverse: false,
 	}
 	if p.Version() >= QUAI1 {
-		id := rand.Uint64()
-
-		requestTracker.Track(p.id, p.version, GetBlockHeadersMsg, BlockHeadersMsg, id)
-		return p2p.Send(p.rw, GetBlockHeadersMsg, &GetBlockHeadersPacket66{
-			RequestId:             id,
-			GetBlockHeadersPacket: &query,
+		return p.sendRequest(GetBlockHeadersMsg, BlockHeadersMsg, func(id uint64) interface{} {
+			return &GetBlockHeadersPacket66{
+				RequestId:             id,
+				GetBlockHeadersPacket: &query,
+			}
 		})
 	}
 	return p2p.Send(p.rw, GetBlockHeadersMsg, &query)
@@ -420,12 +487,11 @@ func (p *Peer) RequestHeadersByHash(origin common.Hash, amount int, skip uint64,
 		Reverse: reverse,
 	}
 	if p.Version() >= QUAI1 {
-		id := rand.Uint64()
-
-		requestTracker.Track(p.id, p.version, GetBlockHeadersMsg, BlockHeadersMsg, id)
-		return p2p.Send(p.rw, GetBlockHeadersMsg, &GetBlockHeadersPacket66{
-			RequestId:             id,
-			GetBlockHeadersPacket: &query,
+		return p.sendRequest(GetBlockHeadersMsg, BlockHeadersMsg, func(id uint64) interface{} {
+			return &GetBlockHeadersPacket66{
+				RequestId:             id,
+				GetBlockHeadersPacket: &query,
+			}
 		})
 	}
 	return p2p.Send(p.rw, GetBlockHeadersMsg, &query)
@@ -439,17 +505,37 @@ func (p *Peer) RequestBlockByHash(hash common.Hash) error {
 		Hash: hash,
 	}
 	if p.Version() >= QUAI1 {
-		id := rand.Uint64()
-
-		requestTracker.Track(p.id, p.version, GetBlockMsg, NewBlockMsg, id)
+		// The block is delivered as a plain NewBlockMsg which doesn't echo the
+		// request id back, so there's nothing to track the request against.
 		return p2p.Send(p.rw, GetBlockMsg, &GetBlockPacket66{
-			RequestId:      id,
+			RequestId:      p.reqIDs.next(),
 			GetBlockPacket: query,
 		})
 	}
 	return p2p.Send(p.rw, GetBlockMsg, &query)
 }
 
+// RequestBlocksByHash fetches a batch of blocks corresponding to the specified
+// hashes in a single round trip. Peers predating quai/4 are asked for the blocks
+// one by one instead.
+func (p *Peer) RequestBlocksByHash(hashes []common.Hash) error {
+	if p.Version() < QUAI4 {
+		for _, hash := range hashes {
+			if err := p.RequestBlockByHash(hash); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	p.Log().Debug("Fetching batch of blocks", "count", len(hashes))
+	return p.sendRequest(GetBlocksMsg, BlocksMsg, func(id uint64) interface{} {
+		return &GetBlocksPacket66{
+			RequestId:       id,
+			GetBlocksPacket: hashes,
+		}
+	})
+}
+
 // RequestHeadersByNumber fetches a batch of blocks' headers corresponding to the
 // specified header query, based on the number of an origin block.
 func (p *Peer) RequestHeadersByNumber(origin uint64, amount int, skip uint64, to uint64, dom bool, reverse bool) error {
@@ -463,12 +549,11 @@ func (p *Peer) RequestHeadersByNumber(origin uint64, amount int, skip uint64, to
 		Reverse: reverse,
 	}
 	if p.Version() >= QUAI1 {
-		id := rand.Uint64()
-
-		requestTracker.Track(p.id, p.version, GetBlockHeadersMsg, BlockHeadersMsg, id)
-		return p2p.Send(p.rw, GetBlockHeadersMsg, &GetBlockHeadersPacket66{
-			RequestId:             id,
-			GetBlockHeadersPacket: &query,
+		return p.sendRequest(GetBlockHeadersMsg, BlockHeadersMsg, func(id uint64) interface{} {
+			return &GetBlockHeadersPacket66{
+				RequestId:             id,
+				GetBlockHeadersPacket: &query,
+			}
 		})
 	}
 	return p2p.Send(p.rw, GetBlockHeadersMsg, &query)
@@ -491,12 +576,11 @@ func (p *Peer) ExpectRequestHeadersByNumber(origin uint64, amount int, dom bool,
 func (p *Peer) RequestBodies(hashes []common.Hash) error {
 	p.Log().Debug("Fetching batch of block bodies", "count", len(hashes))
 	if p.Version() >= QUAI1 {
-		id := rand.Uint64()
-
-		requestTracker.Track(p.id, p.version, GetBlockBodiesMsg, BlockBodiesMsg, id)
-		return p2p.Send(p.rw, GetBlockBodiesMsg, &GetBlockBodiesPacket66{
-			RequestId:            id,
-			GetBlockBodiesPacket: hashes,
+		return p.sendRequest(GetBlockBodiesMsg, BlockBodiesMsg, func(id uint64) interface{} {
+			return &GetBlockBodiesPacket66{
+				RequestId:            id,
+				GetBlockBodiesPacket: hashes,
+			}
 		})
 	}
 	return p2p.Send(p.rw, GetBlockBodiesMsg, GetBlockBodiesPacket(hashes))
@@ -506,13 +590,34 @@ func (p *Peer) RequestBodies(hashes []common.Hash) error {
 func (p *Peer) RequestTxs(hashes []common.Hash) error {
 	p.Log().Debug("Fetching batch of transactions", "count", len(hashes))
 	if p.Version() >= QUAI1 {
-		id := rand.Uint64()
-
-		requestTracker.Track(p.id, p.version, GetPooledTransactionsMsg, PooledTransactionsMsg, id)
-		return p2p.Send(p.rw, GetPooledTransactionsMsg, &GetPooledTransactionsPacket66{
-			RequestId:                   id,
-			GetPooledTransactionsPacket: hashes,
+		return p.sendRequest(GetPooledTransactionsMsg, PooledTransactionsMsg, func(id uint64) interface{} {
+			return &GetPooledTransactionsPacket66{
+				RequestId:                   id,
+				GetPooledTransactionsPacket: hashes,
+			}
 		})
 	}
 	return p2p.Send(p.rw, GetPooledTransactionsMsg, GetPooledTransactionsPacket(hashes))
 }
+
+// sendRequest assigns a fresh request id, registers it in the peer's pending
+// request table and sends the packet built for it. If the id collides with a
+// request still pending since the nonce wrapped around, a few fresh ids are
+// tried. If the request cannot be tracked it is not sent, since its response
+// would be dropped anyway.
+func (p *Peer) sendRequest(reqCode uint64, resCode uint64, packet func(id uint64) interface{}) error {
+	id := p.reqIDs.next()
+	_, err := p.tracker.Track(p.id, p.version, reqCode, resCode, id)
+	for retries := 0; errors.Is(err, tracker.ErrRequestCollision) && retries < maxRequestIDRetries; retries++ {
+		id = p.reqIDs.next()
+		_, err = p.tracker.Track(p.id, p.version, reqCode, resCode, id)
+	}
+	if err != nil {
+		return err
+	}
+	if err := p2p.Send(p.rw, reqCode, packet(id)); err != nil {
+		p.tracker.Cancel(id)
+		return err
+	}
+	return nil
+}
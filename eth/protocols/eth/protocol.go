@@ -30,7 +30,7 @@ import (
 
 // Constants to match up protocol versions and messages
 const (
-	QUAI1, QUAI2 = 102, 103
+	QUAI1, QUAI2, QUAI3, QUAI4 = 102, 103, 104, 105
 )
 
 // ProtocolName is the official short name of the `quai` protocol used during
@@ -39,11 +39,11 @@ const c_ProtocolName = "quai"
 
 // ProtocolVersions are the supported versions of the `eth` protocol (first
 // is primary).
-var ProtocolVersions = []uint{QUAI1, QUAI2}
+var ProtocolVersions = []uint{QUAI1, QUAI2, QUAI3, QUAI4}
 
 // protocolLengths are the number of implemented message corresponding to
 // different protocol versions.
-var protocolLengths = map[uint]uint64{QUAI1: 12, QUAI2: 12}
+var protocolLengths = map[uint]uint64{QUAI1: 12, QUAI2: 12, QUAI3: 12, QUAI4: 14}
 
 // maxMessageSize is the maximum cap on the size of a protocol message.
 const maxMessageSize = 10 * 1024 * 1024
@@ -65,6 +65,10 @@ const (
 	PooledTransactionsMsg         = 0x0a
 
 	GetBlockMsg = 0x0b
+
+	// Protocol messages introduced in quai/4
+	GetBlocksMsg = 0x0c
+	BlocksMsg    = 0x0d
 )
 
 var (
@@ -78,6 +82,7 @@ var (
 	errForkIDRejected          = errors.New("fork ID rejected")
 	errLocationMismatch        = errors.New("location mismatch")
 	errSlicesRunningRejected   = errors.New("slices running not valid")
+	errUnsolicitedResponses    = errors.New("too many unsolicited responses")
 )
 
 // Packet represents a p2p message in the `eth` protocol.
@@ -96,6 +101,7 @@ type StatusPacket struct {
 	Head            common.Hash
 	Genesis         common.Hash
 	ForkID          forkid.ID
+	Time            uint64 `rlp:"optional"` // Sender's clock in unix milliseconds (quai/104+)
 }
 
 // NewBlockHashesPacket is the network packet for the block announcements.
@@ -290,6 +296,30 @@ type GetBlockPacket66 struct {
 	GetBlockPacket
 }
 
+// GetBlocksPacket is the network packet for fetching a batch of blocks by hash.
+type GetBlocksPacket []common.Hash
+
+// GetBlocksPacket66 is the request-id carrying version of GetBlocksPacket.
+type GetBlocksPacket66 struct {
+	RequestId uint64
+	GetBlocksPacket
+}
+
+// BlocksPacket is the network packet answering a GetBlocksPacket. It carries all
+// the requested blocks the remote peer could serve, along with the hashes of
+// those it could not, so the requester doesn't have to wait for them.
+type BlocksPacket struct {
+	Blocks  []*types.Block
+	Missing []common.Hash
+	Entropy *big.Int
+}
+
+// BlocksPacket66 is the request-id carrying version of BlocksPacket.
+type BlocksPacket66 struct {
+	RequestId uint64
+	BlocksPacket
+}
+
 func (*StatusPacket) Name() string { return "Status" }
 func (*StatusPacket) Kind() byte   { return StatusMsg }
 
@@ -325,3 +355,9 @@ func (*PooledTransactionsPacket) Kind() byte   { return PooledTransactionsMsg }
 
 func (*GetBlockPacket) Name() string { return "GetBlock" }
 func (*GetBlockPacket) Kind() byte   { return GetBlockMsg }
+
+func (*GetBlocksPacket) Name() string { return "GetBlocks" }
+func (*GetBlocksPacket) Kind() byte   { return GetBlocksMsg }
+
+func (*BlocksPacket) Name() string { return "Blocks" }
+func (*BlocksPacket) Kind() byte   { return BlocksMsg }
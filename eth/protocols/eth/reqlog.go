@@ -0,0 +1,90 @@
+// Copyright 2023 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package eth
+
+import (
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	"github.com/dominant-strategies/go-quai/common"
+	"github.com/dominant-strategies/go-quai/log"
+)
+
+var (
+	requestLogRate    uint64 // Log one in every requestLogRate requests (0 = disabled)
+	requestLogCounter uint64 // Number of requests seen since the sampling was enabled
+)
+
+// messageNames maps the protocol message codes to human readable names for the
+// request logs.
+var messageNames = map[uint64]string{
+	StatusMsg:                     "Status",
+	NewBlockHashesMsg:             "NewBlockHashes",
+	TransactionsMsg:               "Transactions",
+	GetBlockHeadersMsg:            "GetBlockHeaders",
+	BlockHeadersMsg:               "BlockHeaders",
+	GetBlockBodiesMsg:             "GetBlockBodies",
+	BlockBodiesMsg:                "BlockBodies",
+	NewBlockMsg:                   "NewBlock",
+	NewPooledTransactionHashesMsg: "NewPooledTransactionHashes",
+	GetPooledTransactionsMsg:      "GetPooledTransactions",
+	PooledTransactionsMsg:         "PooledTransactions",
+	GetBlockMsg:                   "GetBlock",
+	GetBlocksMsg:                  "GetBlocks",
+	BlocksMsg:                     "Blocks",
+}
+
+// SetRequestLogSampling sets the rate at which inbound and outbound protocol
+// requests are logged, one in every rate requests. A rate of 0 disables the
+// request logs.
+func SetRequestLogSampling(rate uint64) {
+	atomic.StoreUint64(&requestLogRate, rate)
+}
+
+// isRequestMsg reports whether the message code is a data retrieval request
+// which is answered with a response.
+func isRequestMsg(code uint64) bool {
+	switch code {
+	case GetBlockHeadersMsg, GetBlockBodiesMsg, GetPooledTransactionsMsg, GetBlockMsg, GetBlocksMsg:
+		return true
+	}
+	return false
+}
+
+// sampleRequest reports whether the current request should be logged.
+func sampleRequest() bool {
+	rate := atomic.LoadUint64(&requestLogRate)
+	if rate == 0 {
+		return false
+	}
+	return atomic.AddUint64(&requestLogCounter, 1)%rate == 0
+}
+
+// logRequest emits a structured log entry for a sampled request.
+func logRequest(direction string, peer *Peer, code uint64, elapsed time.Duration, err error) {
+	name, ok := messageNames[code]
+	if !ok {
+		name = fmt.Sprintf("%#02x", code)
+	}
+	outcome := "ok"
+	if err != nil {
+		outcome = err.Error()
+	}
+	log.Info("Sampled protocol request", "direction", direction, "peer", peer.id, "version", peer.version,
+		"type", name, "latency", common.PrettyDuration(elapsed), "outcome", outcome)
+}
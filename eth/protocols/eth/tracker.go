@@ -17,10 +17,53 @@
 package eth
 
 import (
+	"math/rand"
+	"sync"
 	"time"
 
 	"github.com/dominant-strategies/go-quai/p2p/tracker"
 )
 
-// requestTracker is a singleton tracker for eth/66 and newer request times.
-var requestTracker = tracker.New(c_ProtocolName, 5*time.Minute)
+const (
+	// requestTimeout is the maximum time a tracked request may stay pending before
+	// it is considered lost and any late response to it is dropped.
+	requestTimeout = 5 * time.Minute
+
+	// maxRequestIDRetries is the number of fresh ids tried for a request if the
+	// previous ones collided with still pending requests.
+	maxRequestIDRetries = 3
+)
+
+// newRequestTracker creates the pending request table of a single peer
+// connection for eth/66 and newer request ids.
+func newRequestTracker() *tracker.Tracker {
+	return tracker.New(c_ProtocolName, requestTimeout)
+}
+
+// requestIDs generates the request ids of a single peer connection. An id is a
+// connection epoch in the upper 32 bits and a monotonically increasing nonce in
+// the lower 32 bits. The epoch is picked at random when the connection is set
+// up, so ids are not reused across reconnects and restarts, and is advanced
+// every time the nonce wraps around, so ids are not reused within a connection
+// either.
+type requestIDs struct {
+	epoch uint32
+	nonce uint32
+	lock  sync.Mutex
+}
+
+func newRequestIDs() *requestIDs {
+	return &requestIDs{epoch: rand.Uint32()}
+}
+
+// next returns a fresh request id.
+func (r *requestIDs) next() uint64 {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	r.nonce++
+	if r.nonce == 0 {
+		r.epoch++
+	}
+	return uint64(r.epoch)<<32 | uint64(r.nonce)
+}
@@ -0,0 +1,110 @@
+// Copyright 2023 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package eth
+
+import (
+	"sync"
+	"time"
+
+	"github.com/dominant-strategies/go-quai/common"
+	lru "github.com/hashicorp/golang-lru"
+)
+
+const (
+	// c_provenanceCacheSize is the Max number of recent blocks whose propagation
+	// path is remembered
+	c_provenanceCacheSize = 1024
+)
+
+// BlockProvenance describes how a recent block propagated through the node.
+type BlockProvenance struct {
+	From       string         `json:"from"`       // Peer the block was first received from (empty if local)
+	ReceivedAt time.Time      `json:"receivedAt"` // Time the block was first received
+	Forwarded  []BlockForward `json:"forwarded"`  // Peers the block was sent or announced to
+}
+
+// BlockForward describes a single forwarding of a block to a peer.
+type BlockForward struct {
+	Peer      string    `json:"peer"`
+	At        time.Time `json:"at"`
+	Announced bool      `json:"announced"` // Whether only the hash was announced
+}
+
+// provenanceTracker records the propagation path of recent blocks. A nil tracker
+// is valid and records nothing.
+type provenanceTracker struct {
+	blocks *lru.Cache // Block hash -> *BlockProvenance
+	lock   sync.Mutex
+}
+
+func newProvenanceTracker() *provenanceTracker {
+	blocks, _ := lru.New(c_provenanceCacheSize)
+	return &provenanceTracker{blocks: blocks}
+}
+
+// received records the arrival of a block from a peer, if it is the first time
+// the block is seen.
+func (t *provenanceTracker) received(hash common.Hash, peer string, at time.Time) {
+	if t == nil {
+		return
+	}
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	if !t.blocks.Contains(hash) {
+		t.blocks.Add(hash, &BlockProvenance{From: peer, ReceivedAt: at})
+	}
+}
+
+// forwarded records the sending or announcement of a block to the given peers.
+func (t *provenanceTracker) forwarded(hash common.Hash, peers []*ethPeer, announced bool) {
+	if t == nil || len(peers) == 0 {
+		return
+	}
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	var prov *BlockProvenance
+	if entry, ok := t.blocks.Get(hash); ok {
+		prov = entry.(*BlockProvenance)
+	} else {
+		// Locally produced block, or one inserted without being broadcast to us
+		prov = &BlockProvenance{ReceivedAt: time.Now()}
+		t.blocks.Add(hash, prov)
+	}
+	now := time.Now()
+	for _, peer := range peers {
+		prov.Forwarded = append(prov.Forwarded, BlockForward{Peer: peer.ID(), At: now, Announced: announced})
+	}
+}
+
+// get retrieves a copy of the propagation path of a block, if known.
+func (t *provenanceTracker) get(hash common.Hash) *BlockProvenance {
+	if t == nil {
+		return nil
+	}
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	entry, ok := t.blocks.Peek(hash)
+	if !ok {
+		return nil
+	}
+	prov := *entry.(*BlockProvenance)
+	prov.Forwarded = append([]BlockForward(nil), prov.Forwarded...)
+	return &prov
+}
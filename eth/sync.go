@@ -17,8 +17,10 @@
 package eth
 
 import (
+	"errors"
 	"math/big"
 	"math/rand"
+	"sync/atomic"
 
 	"github.com/dominant-strategies/go-quai/common"
 	"github.com/dominant-strategies/go-quai/core/types"
@@ -227,6 +229,13 @@ func (cs *chainSyncer) nextSyncOp() *chainSyncOp {
 	if cs.doneCh != nil {
 		return nil // Sync already running.
 	}
+	if atomic.LoadUint32(&cs.handler.syncPaused) == 1 {
+		return nil // Sync paused by the operator.
+	}
+	if target := cs.handler.downloader.SyncTarget(); target != 0 && cs.handler.core.CurrentHeader().NumberU64() >= target {
+		cs.handler.pauseSync()
+		return nil // Sync target already reached.
+	}
 
 	// Ensure we're at minimum peer count.
 	minPeers := defaultMinSyncPeers
@@ -266,6 +275,49 @@ func (cs *chainSyncer) startSync(op *chainSyncOp) {
 	go func() { cs.doneCh <- cs.handler.doSync(op) }()
 }
 
+// pauseSync aborts the running sync cycle, if any, and keeps new ones from being
+// started until resumeSync is called. Blocks propagated by peers are ignored in
+// the meantime. It returns false if sync was already paused.
+func (h *handler) pauseSync() bool {
+	if !atomic.CompareAndSwapUint32(&h.syncPaused, 0, 1) {
+		return false
+	}
+	h.downloader.Cancel()
+	log.Info("Chain sync paused")
+	return true
+}
+
+// setSyncTarget sets the block number to sync to, after which sync is paused
+// until resumed. Zero removes the target.
+func (h *handler) setSyncTarget(number uint64) {
+	h.downloader.SetSyncTarget(number)
+	if number != 0 {
+		log.Info("Syncing up to target block", "number", number)
+	}
+}
+
+// beyondSyncTarget reports whether a block propagated by a peer must be ignored,
+// because sync is paused or the block is past the sync target.
+func (h *handler) beyondSyncTarget(number uint64) bool {
+	if atomic.LoadUint32(&h.syncPaused) == 1 {
+		return true
+	}
+	target := h.downloader.SyncTarget()
+	return target != 0 && number > target
+}
+
+// resumeSync allows sync cycles to be started again after pauseSync. It returns
+// false if sync was not paused.
+func (h *handler) resumeSync() bool {
+	if !atomic.CompareAndSwapUint32(&h.syncPaused, 1, 0) {
+		return false
+	}
+	log.Info("Chain sync resumed")
+	// Recheck the peers right away instead of waiting for the next peer event
+	go h.chainSync.handlePeerEvent(nil)
+	return true
+}
+
 // doSync synchronizes the local blockchain with a remote peer.
 func (h *handler) doSync(op *chainSyncOp) error {
 	// Stopping the downloader here temporarily for Region and Zones
@@ -274,6 +326,10 @@ func (h *handler) doSync(op *chainSyncOp) error {
 		// Run the sync cycle, and disable fast sync if we're past the pivot block
 		err := h.downloader.Synchronise(op.peer.ID(), op.head, op.entropy, op.mode)
 		log.Info("Downloader exited", "err", err)
+		if errors.Is(err, downloader.ErrSyncTargetReached) {
+			h.pauseSync()
+			return nil
+		}
 		if err != nil {
 			return err
 		}
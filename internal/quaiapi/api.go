@@ -240,12 +240,13 @@ func (s *PublicTxPoolAPI) Inspect() map[string]map[string]map[string]string {
 // PublicBlockChainAPI provides an API to access the Quai blockchain.
 // It offers only methods that operate on public data that is freely available to anyone.
 type PublicBlockChainAPI struct {
-	b Backend
+	b          Backend
+	blockCache *responseCache
 }
 
 // NewPublicBlockChainAPI creates a new Quai blockchain API.
 func NewPublicBlockChainAPI(b Backend) *PublicBlockChainAPI {
-	return &PublicBlockChainAPI{b}
+	return &PublicBlockChainAPI{b, newResponseCache()}
 }
 
 // ChainId is the replay-protection chain id for the current Quai chain config.
@@ -384,6 +385,18 @@ func (s *PublicBlockChainAPI) GetHeaderByHash(ctx context.Context, hash common.H
 //   - When fullTx is true all transactions in the block are returned, otherwise
 //     only the transaction hash is returned.
 func (s *PublicBlockChainAPI) GetBlockByNumber(ctx context.Context, number rpc.BlockNumber, fullTx bool) (map[string]interface{}, error) {
+	// Serve blocks deep in the chain from the cache if possible
+	type cacheKey struct {
+		number rpc.BlockNumber
+		fullTx bool
+	}
+	key := cacheKey{number, fullTx}
+	hash, cacheable := s.blockCache.cacheable(ctx, s.b, number)
+	if cacheable {
+		if response, ok := s.blockCache.get(key, hash); ok {
+			return response.(map[string]interface{}), nil
+		}
+	}
 	block, err := s.b.BlockByNumber(ctx, number)
 	if block != nil && err == nil {
 		response, err := s.rpcMarshalBlock(ctx, block, true, fullTx)
@@ -393,6 +406,9 @@ func (s *PublicBlockChainAPI) GetBlockByNumber(ctx context.Context, number rpc.B
 				response[field] = nil
 			}
 		}
+		if err == nil && cacheable && block.Hash() == hash {
+			s.blockCache.add(key, hash, response)
+		}
 		return response, err
 	}
 	return nil, err
@@ -0,0 +1,86 @@
+// Copyright 2023 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package quaiapi
+
+import (
+	"context"
+
+	"github.com/dominant-strategies/go-quai/common"
+	"github.com/dominant-strategies/go-quai/rpc"
+	lru "github.com/hashicorp/golang-lru"
+)
+
+const (
+	// c_responseCacheSize is the Max number of responses kept per cache
+	c_responseCacheSize = 1024
+
+	// c_responseCacheDepth is the number of blocks behind the head past which
+	// a block is considered unlikely to be reorged, and its responses cached
+	c_responseCacheDepth = 16
+)
+
+// responseCache caches the responses of read-heavy RPC methods queried by block
+// number, for blocks deep enough in the chain. Every entry remembers the hash
+// of the canonical block it was derived from, and is discarded on lookup if a
+// reorg has replaced that block since.
+type responseCache struct {
+	cache *lru.Cache
+}
+
+type cachedResponse struct {
+	hash     common.Hash
+	response interface{}
+}
+
+func newResponseCache() *responseCache {
+	cache, _ := lru.New(c_responseCacheSize)
+	return &responseCache{cache: cache}
+}
+
+// cacheable reports whether responses about the given block number may be
+// cached, returning the hash of the canonical block at that height.
+func (c *responseCache) cacheable(ctx context.Context, b Backend, number rpc.BlockNumber) (common.Hash, bool) {
+	if number < 0 || uint64(number)+c_responseCacheDepth > b.CurrentHeader().NumberU64() {
+		return common.Hash{}, false
+	}
+	header, err := b.HeaderByNumber(ctx, number)
+	if header == nil || err != nil {
+		return common.Hash{}, false
+	}
+	return header.Hash(), true
+}
+
+// get retrieves the cached response for the key, if it was derived from the
+// block with the given hash.
+func (c *responseCache) get(key interface{}, hash common.Hash) (interface{}, bool) {
+	entry, ok := c.cache.Get(key)
+	if !ok {
+		return nil, false
+	}
+	if cached := entry.(cachedResponse); cached.hash == hash {
+		return cached.response, true
+	}
+	// The block was reorged out, drop the stale response
+	c.cache.Remove(key)
+	return nil, false
+}
+
+// add caches the response for the key, derived from the block with the given
+// hash.
+func (c *responseCache) add(key interface{}, hash common.Hash, response interface{}) {
+	c.cache.Add(key, cachedResponse{hash: hash, response: response})
+}
@@ -119,12 +119,13 @@ func (s *PublicQuaiAPI) Syncing() (interface{}, error) {
 // PublicBlockChainQuaiAPI provides an API to access the Quai blockchain.
 // It offers only methods that operate on public data that is freely available to anyone.
 type PublicBlockChainQuaiAPI struct {
-	b Backend
+	b          Backend
+	blockCache *responseCache
 }
 
 // NewPublicBlockChainQuaiAPI creates a new Quai blockchain API.
 func NewPublicBlockChainQuaiAPI(b Backend) *PublicBlockChainQuaiAPI {
-	return &PublicBlockChainQuaiAPI{b}
+	return &PublicBlockChainQuaiAPI{b, newResponseCache()}
 }
 
 // ChainId is the replay-protection chain id for the current Quai chain config.
@@ -269,6 +270,18 @@ func (s *PublicBlockChainQuaiAPI) GetHeaderByHash(ctx context.Context, hash comm
 //   - When fullTx is true all transactions in the block are returned, otherwise
 //     only the transaction hash is returned.
 func (s *PublicBlockChainQuaiAPI) GetBlockByNumber(ctx context.Context, number rpc.BlockNumber, fullTx bool) (map[string]interface{}, error) {
+	// Serve blocks deep in the chain from the cache if possible
+	type cacheKey struct {
+		number rpc.BlockNumber
+		fullTx bool
+	}
+	key := cacheKey{number, fullTx}
+	hash, cacheable := s.blockCache.cacheable(ctx, s.b, number)
+	if cacheable {
+		if response, ok := s.blockCache.get(key, hash); ok {
+			return response.(map[string]interface{}), nil
+		}
+	}
 	block, err := s.b.BlockByNumber(ctx, number)
 	if block != nil && err == nil {
 		response, err := s.rpcMarshalBlock(ctx, block, true, fullTx)
@@ -278,6 +291,9 @@ func (s *PublicBlockChainQuaiAPI) GetBlockByNumber(ctx context.Context, number r
 				response[field] = nil
 			}
 		}
+		if err == nil && cacheable && block.Hash() == hash {
+			s.blockCache.add(key, hash, response)
+		}
 		return response, err
 	}
 	return nil, err
@@ -0,0 +1,7 @@
+package metrics
+
+// FileStats is the per process file descriptor usage.
+type FileStats struct {
+	Open  int64 // Number of currently open file descriptors
+	Limit int64 // Maximum number of file descriptors the process may open
+}
@@ -0,0 +1,21 @@
+package metrics
+
+import (
+	"os"
+	"syscall"
+)
+
+// ReadFileStats retrieves the file descriptor usage of the current process.
+func ReadFileStats(stats *FileStats) error {
+	fds, err := os.ReadDir("/proc/self/fd")
+	if err != nil {
+		return err
+	}
+	var limit syscall.Rlimit
+	if err := syscall.Getrlimit(syscall.RLIMIT_NOFILE, &limit); err != nil {
+		return err
+	}
+	stats.Open = int64(len(fds))
+	stats.Limit = int64(limit.Cur)
+	return nil
+}
@@ -0,0 +1,11 @@
+//go:build !linux
+// +build !linux
+
+package metrics
+
+import "errors"
+
+// ReadFileStats retrieves the file descriptor usage of the current process.
+func ReadFileStats(stats *FileStats) error {
+	return errors.New("Not implemented")
+}
@@ -54,6 +54,16 @@ func init() {
 	}
 }
 
+const (
+	// goroutineWarnThreshold is the number of goroutines above which the process
+	// is suspected of leaking them.
+	goroutineWarnThreshold = 20000
+
+	// fileWarnPercent is the percentage of the file descriptor allowance above
+	// which the process is suspected of leaking them.
+	fileWarnPercent = 90
+)
+
 // CollectProcessMetrics periodically collects various metrics about the running
 // process.
 func CollectProcessMetrics(refresh time.Duration) {
@@ -92,6 +102,15 @@ func CollectProcessMetrics(refresh time.Duration) {
 		diskWrites            = GetOrRegisterMeter("system/disk/writecount", DefaultRegistry)
 		diskWriteBytes        = GetOrRegisterMeter("system/disk/writedata", DefaultRegistry)
 		diskWriteBytesCounter = GetOrRegisterCounter("system/disk/writebytes", DefaultRegistry)
+
+		filesOpen  = GetOrRegisterGauge("system/files/open", DefaultRegistry)
+		filesLimit = GetOrRegisterGauge("system/files/limit", DefaultRegistry)
+	)
+	// Warnings are only raised when a threshold is crossed, not on every refresh
+	var (
+		fileStats        = new(FileStats)
+		goroutinesWarned bool
+		filesWarned      bool
 	)
 	// Iterate loading the different stats and updating the meters
 	for i := 1; ; i++ {
@@ -103,7 +122,14 @@ func CollectProcessMetrics(refresh time.Duration) {
 		cpuSysWait.Update((cpuStats[location1].GlobalWait - cpuStats[location2].GlobalWait) / refreshFreq)
 		cpuProcLoad.Update((cpuStats[location1].LocalTime - cpuStats[location2].LocalTime) / refreshFreq)
 		cpuThreads.Update(int64(threadCreateProfile.Count()))
-		cpuGoroutines.Update(int64(runtime.NumGoroutine()))
+		goroutines := runtime.NumGoroutine()
+		cpuGoroutines.Update(int64(goroutines))
+		if exceeded := goroutines > goroutineWarnThreshold; exceeded != goroutinesWarned {
+			if exceeded {
+				log.Warn("Goroutine count exceeds threshold", "goroutines", goroutines, "threshold", goroutineWarnThreshold)
+			}
+			goroutinesWarned = exceeded
+		}
 
 		runtime.ReadMemStats(memstats[location1])
 		memPauses.Mark(int64(memstats[location1].PauseTotalNs - memstats[location2].PauseTotalNs))
@@ -121,6 +147,17 @@ func CollectProcessMetrics(refresh time.Duration) {
 			diskReadBytesCounter.Inc(diskstats[location1].ReadBytes - diskstats[location2].ReadBytes)
 			diskWriteBytesCounter.Inc(diskstats[location1].WriteBytes - diskstats[location2].WriteBytes)
 		}
+		if ReadFileStats(fileStats) == nil {
+			filesOpen.Update(fileStats.Open)
+			filesLimit.Update(fileStats.Limit)
+
+			if exceeded := fileStats.Limit > 0 && fileStats.Open*100 > fileStats.Limit*fileWarnPercent; exceeded != filesWarned {
+				if exceeded {
+					log.Warn("Open file descriptors nearing limit", "open", fileStats.Open, "limit", fileStats.Limit)
+				}
+				filesWarned = exceeded
+			}
+		}
 		time.Sleep(refresh)
 	}
 }
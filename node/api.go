@@ -185,6 +185,7 @@ func (api *privateAdminAPI) StartHTTP(host *string, port *int, cors *string, api
 		CorsAllowedOrigins: api.node.config.HTTPCors,
 		Vhosts:             api.node.config.HTTPVirtualHosts,
 		Modules:            api.node.config.HTTPModules,
+		apiKeys:            api.node.apiKeys,
 	}
 	if cors != nil {
 		config.CorsAllowedOrigins = nil
@@ -258,6 +259,7 @@ func (api *privateAdminAPI) StartWS(host *string, port *int, allowedOrigins *str
 	config := wsConfig{
 		Modules: api.node.config.WSModules,
 		Origins: api.node.config.WSOrigins,
+		apiKeys: api.node.apiKeys,
 		// ExposeAll: api.node.config.WSExposeAll,
 	}
 	if apis != nil {
@@ -0,0 +1,205 @@
+// Copyright 2023 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package node
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"time"
+
+	"github.com/dominant-strategies/go-quai/metrics"
+	"golang.org/x/time/rate"
+)
+
+const (
+	// apiKeyHeader is the HTTP header carrying the API key of a request.
+	apiKeyHeader = "X-API-Key"
+
+	// apiKeyParam is the URL query parameter carrying the API key of a request,
+	// for clients unable to set custom headers (e.g. browser websockets).
+	apiKeyParam = "apikey"
+
+	// maxAPIKeyBodySize is the maximum request body inspected for the method
+	// allowlist, matching the limit enforced by the RPC server.
+	maxAPIKeyBodySize = 5 * 1024 * 1024
+)
+
+// APIKey is an API key accepted on the public RPC endpoints, along with the
+// quotas and permissions of the tenant it was issued to.
+type APIKey struct {
+	Key     string   `json:"key"`     // Secret value sent by the client
+	Name    string   `json:"name"`    // Tenant name used in logs and metrics
+	Rate    float64  `json:"rate"`    // Allowed calls per second (0 = unlimited)
+	Burst   int      `json:"burst"`   // Allowed burst of calls above the rate, and max batch size
+	Methods []string `json:"methods"` // Allowed RPC methods (empty = all)
+}
+
+// LoadAPIKeys reads the API keys from the JSON file at the given path. An empty
+// path means no API keys are configured.
+func LoadAPIKeys(path string) ([]APIKey, error) {
+	if path == "" {
+		return nil, nil
+	}
+	blob, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var keys []APIKey
+	if err := json.Unmarshal(blob, &keys); err != nil {
+		return nil, fmt.Errorf("invalid API key file %s: %v", path, err)
+	}
+	seen := make(map[string]struct{})
+	for i, key := range keys {
+		if key.Key == "" {
+			return nil, fmt.Errorf("API key %d has no key", i)
+		}
+		if _, ok := seen[key.Key]; ok {
+			return nil, fmt.Errorf("API key %d (%s) is a duplicate", i, key.Name)
+		}
+		seen[key.Key] = struct{}{}
+	}
+	return keys, nil
+}
+
+// apiKeyTenant is the runtime state of a single API key.
+type apiKeyTenant struct {
+	limiter  *rate.Limiter       // nil if the key is not rate limited
+	methods  map[string]struct{} // nil if all methods are allowed
+	requests metrics.Counter     // Number of RPC calls served to the key
+	rejected metrics.Counter     // Number of requests rejected for the key
+}
+
+// apiKeyHandler is a handler which requires a valid API key on every request,
+// and enforces the rate limit and method allowlist of the key.
+type apiKeyHandler struct {
+	tenants map[string]*apiKeyTenant
+	next    http.Handler
+}
+
+func newAPIKeyHandler(keys []APIKey, next http.Handler) http.Handler {
+	tenants := make(map[string]*apiKeyTenant, len(keys))
+	for _, key := range keys {
+		name := key.Name
+		if name == "" {
+			name = "unnamed"
+		}
+		tenant := &apiKeyTenant{
+			requests: metrics.GetOrRegisterCounter("rpc/apikeys/"+name+"/requests", nil),
+			rejected: metrics.GetOrRegisterCounter("rpc/apikeys/"+name+"/rejected", nil),
+		}
+		if key.Rate > 0 {
+			burst := key.Burst
+			if burst < 1 {
+				burst = 1
+			}
+			tenant.limiter = rate.NewLimiter(rate.Limit(key.Rate), burst)
+		}
+		if len(key.Methods) > 0 {
+			tenant.methods = make(map[string]struct{}, len(key.Methods))
+			for _, method := range key.Methods {
+				tenant.methods[method] = struct{}{}
+			}
+		}
+		tenants[key.Key] = tenant
+	}
+	return &apiKeyHandler{tenants: tenants, next: next}
+}
+
+// ServeHTTP serves JSON-RPC requests over HTTP, implements http.Handler
+func (h *apiKeyHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	key := r.Header.Get(apiKeyHeader)
+	if key == "" {
+		key = r.URL.Query().Get(apiKeyParam)
+	}
+	tenant, ok := h.tenants[key]
+	if !ok {
+		http.Error(w, "invalid or missing API key", http.StatusUnauthorized)
+		return
+	}
+	if isWebsocket(r) {
+		// Individual websocket messages can't be inspected here, so keys with a
+		// rate limit or limited to a set of methods may only be used over HTTP
+		if tenant.limiter != nil || tenant.methods != nil {
+			tenant.rejected.Inc(1)
+			http.Error(w, "API key is restricted to HTTP", http.StatusForbidden)
+			return
+		}
+		tenant.requests.Inc(1)
+		h.next.ServeHTTP(w, r)
+		return
+	}
+	body, err := ioutil.ReadAll(http.MaxBytesReader(w, r.Body, maxAPIKeyBodySize))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusRequestEntityTooLarge)
+		return
+	}
+	r.Body = ioutil.NopCloser(bytes.NewReader(body))
+
+	// Every call of a batch counts against the quota of the key
+	methods, err := rpcMethods(body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if tenant.limiter != nil && !tenant.limiter.AllowN(time.Now(), len(methods)) {
+		tenant.rejected.Inc(1)
+		http.Error(w, "API key rate limit exceeded", http.StatusTooManyRequests)
+		return
+	}
+	if tenant.methods != nil {
+		for _, method := range methods {
+			if _, ok := tenant.methods[method]; !ok {
+				tenant.rejected.Inc(1)
+				http.Error(w, fmt.Sprintf("method %s not allowed for API key", method), http.StatusForbidden)
+				return
+			}
+		}
+	}
+	tenant.requests.Inc(int64(len(methods)))
+	h.next.ServeHTTP(w, r)
+}
+
+// rpcMethods returns the methods called by a single or batch JSON-RPC request.
+// An empty body, as sent by health checks, calls no methods.
+func rpcMethods(body []byte) ([]string, error) {
+	type call struct {
+		Method string `json:"method"`
+	}
+	body = bytes.TrimSpace(body)
+	if len(body) == 0 {
+		return nil, nil
+	}
+	if len(body) > 0 && body[0] == '[' {
+		var batch []call
+		if err := json.Unmarshal(body, &batch); err != nil {
+			return nil, err
+		}
+		methods := make([]string, len(batch))
+		for i, c := range batch {
+			methods[i] = c.Method
+		}
+		return methods, nil
+	}
+	var single call
+	if err := json.Unmarshal(body, &single); err != nil {
+		return nil, err
+	}
+	return []string{single.Method}, nil
+}
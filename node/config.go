@@ -39,6 +39,7 @@ const (
 	datadirStaticNodes     = "static-nodes.json"  // Path within the datadir to the static node list
 	datadirTrustedNodes    = "trusted-nodes.json" // Path within the datadir to the trusted node list
 	datadirNodeDatabase    = "nodes"              // Path within the datadir to store the node infos
+	datadirChainDatabase   = "chaindata"          // Path within the datadir to store the chain database
 )
 
 // Config represents a small collection of configuration values to fine tune the
@@ -76,6 +77,17 @@ type Config struct {
 	// is created by New and destroyed when the node is stopped.
 	KeyStoreDir string `toml:",omitempty"`
 
+	// ChainDataDir is the file system folder of the chain database, allowing it to
+	// be placed on a different storage device than the rest of the data directory.
+	// A relative path is resolved relative to the instance directory. If empty, the
+	// "chaindata" subdirectory of the instance directory is used.
+	ChainDataDir string `toml:",omitempty"`
+
+	// NodeDatabaseDir is the file system folder of the discovery node database. A
+	// relative path is resolved relative to the instance directory. If empty, the
+	// "nodes" subdirectory of the instance directory is used.
+	NodeDatabaseDir string `toml:",omitempty"`
+
 	// ExternalSigner specifies an external URI for a clef-type signer
 	ExternalSigner string `toml:",omitempty"`
 
@@ -127,6 +139,11 @@ type Config struct {
 	// HTTPPathPrefix specifies a path prefix on which http-rpc is to be served.
 	HTTPPathPrefix string `toml:",omitempty"`
 
+	// RPCAPIKeysFile is the path to a JSON file listing the API keys required on
+	// the HTTP and WebSocket RPC endpoints, along with their rate limits and
+	// allowed methods. If empty, no API key is required.
+	RPCAPIKeysFile string `toml:",omitempty"`
+
 	// WSHost is the host interface on which to start the websocket RPC server. If
 	// this field is empty, no websocket API endpoint will be started.
 	WSHost string
@@ -180,9 +197,21 @@ func (c *Config) NodeDB() string {
 	if c.DataDir == "" {
 		return "" // ephemeral
 	}
+	if c.NodeDatabaseDir != "" {
+		return c.ResolvePath(c.NodeDatabaseDir)
+	}
 	return c.ResolvePath(datadirNodeDatabase)
 }
 
+// ChainDB returns the name of the chain database, to be opened with
+// OpenDatabase or OpenDatabaseWithFreezer.
+func (c *Config) ChainDB() string {
+	if c.ChainDataDir != "" {
+		return c.ResolvePath(c.ChainDataDir)
+	}
+	return datadirChainDatabase
+}
+
 // HTTPEndpoint resolves an HTTP endpoint based on the configured host interface
 // and port parameters.
 func (c *Config) HTTPEndpoint() string {
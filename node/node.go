@@ -42,6 +42,7 @@ type Node struct {
 	log           log.Logger
 	dirLock       fileutil.Releaser // prevents concurrent use of instance directory
 	stop          chan struct{}     // Channel to wait for termination notifications
+	stopping      chan struct{}     // Channel closed once the shutdown begins
 	server        *p2p.Server       // Currently running P2P networking layer
 	startStopLock sync.Mutex        // Start/Stop are protected by an additional lock
 	state         int               // Tracks state of node lifecycle
@@ -52,8 +53,12 @@ type Node struct {
 	http          *httpServer //
 	ws            *httpServer //
 	inprocHandler *rpc.Server // In-process RPC request handler to process the API requests
+	apiKeys       []APIKey    // API keys required on the HTTP and WS endpoints, if any
 
 	databases map[*closeTrackingDB]struct{} // All open databases
+
+	shutdown     ShutdownReport // Progress and outcome of stopping the node
+	shutdownLock sync.Mutex
 }
 
 const (
@@ -94,6 +99,7 @@ func New(conf *Config) (*Node, error) {
 		eventmux:      new(event.TypeMux),
 		log:           *conf.Logger,
 		stop:          make(chan struct{}),
+		stopping:      make(chan struct{}),
 		server:        &p2p.Server{Config: conf.P2P},
 		databases:     make(map[*closeTrackingDB]struct{}),
 	}
@@ -211,13 +217,21 @@ func (n *Node) doClose(errs []error) error {
 	// synchronize with OpenDatabase*.
 	n.lock.Lock()
 	n.state = closedState
-	errs = append(errs, n.closeDatabases()...)
+	n.stopSubsystem("databases", func() error {
+		dbErrs := n.closeDatabases()
+		errs = append(errs, dbErrs...)
+		if len(dbErrs) > 0 {
+			return fmt.Errorf("%v", dbErrs)
+		}
+		return nil
+	})
 	n.lock.Unlock()
 
 	// Release instance directory lock.
 	n.closeDataDir()
 
 	// Unblock n.Wait.
+	n.finishShutdown()
 	close(n.stop)
 
 	// Report any errors that might have occurred.
@@ -260,18 +274,25 @@ func containsLifecycle(lfs []Lifecycle, l Lifecycle) bool {
 // stopServices terminates running services, RPC and p2p networking.
 // It is the inverse of Start.
 func (n *Node) stopServices(running []Lifecycle) error {
-	n.stopRPC()
+	n.stopSubsystem("rpc", func() error {
+		n.stopRPC()
+		return nil
+	})
 
 	// Stop running lifecycles in reverse order.
 	failure := &StopError{Services: make(map[reflect.Type]error)}
 	for i := len(running) - 1; i >= 0; i-- {
-		if err := running[i].Stop(); err != nil {
-			failure.Services[reflect.TypeOf(running[i])] = err
+		kind := reflect.TypeOf(running[i])
+		if err := n.stopSubsystem(kind.String(), running[i].Stop); err != nil {
+			failure.Services[kind] = err
 		}
 	}
 
 	// Stop p2p networking.
-	n.server.Stop()
+	n.stopSubsystem("p2p", func() error {
+		n.server.Stop()
+		return nil
+	})
 
 	if len(failure.Services) > 0 {
 		return failure
@@ -312,6 +333,12 @@ func (n *Node) closeDataDir() {
 // startup. It's not meant to be called at any time afterwards as it makes certain
 // assumptions about the state of the node.
 func (n *Node) startRPC() error {
+	apiKeys, err := LoadAPIKeys(n.config.RPCAPIKeysFile)
+	if err != nil {
+		return err
+	}
+	n.apiKeys = apiKeys
+
 	if err := n.startInProc(); err != nil {
 		return err
 	}
@@ -323,6 +350,7 @@ func (n *Node) startRPC() error {
 			Vhosts:             n.config.HTTPVirtualHosts,
 			Modules:            n.config.HTTPModules,
 			prefix:             n.config.HTTPPathPrefix,
+			apiKeys:            n.apiKeys,
 		}
 		if err := n.http.setListenAddr(n.config.HTTPHost, n.config.HTTPPort); err != nil {
 			return err
@@ -339,6 +367,7 @@ func (n *Node) startRPC() error {
 			Modules: n.config.WSModules,
 			Origins: n.config.WSOrigins,
 			prefix:  n.config.WSPathPrefix,
+			apiKeys: n.apiKeys,
 		}
 		if err := server.setListenAddr(n.config.WSHost, n.config.WSPort); err != nil {
 			return err
@@ -495,6 +524,13 @@ func (n *Node) WSEndpoint() string {
 	return "ws://" + n.ws.listenAddr() + n.ws.wsConfig.prefix
 }
 
+// ModuleAuthenticated returns whether every HTTP and WS endpoint currently
+// serving the given RPC module either requires API keys or only listens on a
+// loopback interface. The in-process endpoint is always considered authenticated.
+func (n *Node) ModuleAuthenticated(module string) bool {
+	return n.http.moduleAuthenticated(module) && n.ws.moduleAuthenticated(module)
+}
+
 // EventMux retrieves the event multiplexer used by all the network services in
 // the current protocol stack.
 func (n *Node) EventMux() *event.TypeMux {
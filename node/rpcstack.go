@@ -39,14 +39,16 @@ type httpConfig struct {
 	Modules            []string
 	CorsAllowedOrigins []string
 	Vhosts             []string
-	prefix             string // path prefix on which to mount http handler
+	prefix             string   // path prefix on which to mount http handler
+	apiKeys            []APIKey // API keys required on requests, if any
 }
 
 // wsConfig is the JSON-RPC/Websocket configuration
 type wsConfig struct {
 	Origins []string
 	Modules []string
-	prefix  string // path prefix on which to mount ws handler
+	prefix  string   // path prefix on which to mount ws handler
+	apiKeys []APIKey // API keys required on requests, if any
 }
 
 type rpcHandler struct {
@@ -284,8 +286,12 @@ func (h *httpServer) enableRPC(apis []rpc.API, config httpConfig) error {
 		return err
 	}
 	h.httpConfig = config
+	var handler http.Handler = srv
+	if len(config.apiKeys) > 0 {
+		handler = newAPIKeyHandler(config.apiKeys, handler)
+	}
 	h.httpHandler.Store(&rpcHandler{
-		Handler: NewHTTPHandlerStack(srv, config.CorsAllowedOrigins, config.Vhosts),
+		Handler: NewHTTPHandlerStack(handler, config.CorsAllowedOrigins, config.Vhosts),
 		server:  srv,
 	})
 	return nil
@@ -316,8 +322,12 @@ func (h *httpServer) enableWS(apis []rpc.API, config wsConfig) error {
 		return err
 	}
 	h.wsConfig = config
+	handler := srv.WebsocketHandler(config.Origins)
+	if len(config.apiKeys) > 0 {
+		handler = newAPIKeyHandler(config.apiKeys, handler)
+	}
 	h.wsHandler.Store(&rpcHandler{
-		Handler: srv.WebsocketHandler(config.Origins),
+		Handler: handler,
 		server:  srv,
 	})
 	return nil
@@ -355,6 +365,37 @@ func (h *httpServer) wsAllowed() bool {
 	return h.wsHandler.Load().(*rpcHandler) != nil
 }
 
+// moduleAuthenticated returns whether the given RPC module, if served by the
+// server, is only reachable over a loopback interface or with an API key.
+func (h *httpServer) moduleAuthenticated(module string) bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if isLoopbackHost(h.host) {
+		return true
+	}
+	if h.rpcAllowed() && containsModule(h.httpConfig.Modules, module) && len(h.httpConfig.apiKeys) == 0 {
+		return false
+	}
+	if h.wsAllowed() && containsModule(h.wsConfig.Modules, module) && len(h.wsConfig.apiKeys) == 0 {
+		return false
+	}
+	return true
+}
+
+func isLoopbackHost(host string) bool {
+	return host == "localhost" || net.ParseIP(host).IsLoopback()
+}
+
+func containsModule(modules []string, module string) bool {
+	for _, m := range modules {
+		if m == module {
+			return true
+		}
+	}
+	return false
+}
+
 // isWebsocket checks the header of an http request for a websocket upgrade request.
 func isWebsocket(r *http.Request) bool {
 	return strings.ToLower(r.Header.Get("Upgrade")) == "websocket" &&
@@ -229,6 +229,42 @@ func Test_checkPath(t *testing.T) {
 	}
 }
 
+// TestModuleAuthenticated tests that a module is only considered authenticated
+// if every handler serving it listens on loopback or requires API keys.
+func TestModuleAuthenticated(t *testing.T) {
+	keys := []APIKey{{Key: "secret"}}
+	tests := []struct {
+		host string
+		http *httpConfig
+		ws   *wsConfig
+		want bool
+	}{
+		{"0.0.0.0", nil, nil, true},
+		{"0.0.0.0", &httpConfig{Modules: []string{"eth"}}, nil, true},
+		{"0.0.0.0", &httpConfig{Modules: []string{"admin"}}, nil, false},
+		{"0.0.0.0", &httpConfig{Modules: []string{"admin"}, apiKeys: []APIKey{}}, nil, false},
+		{"0.0.0.0", &httpConfig{Modules: []string{"admin"}, apiKeys: keys}, nil, true},
+		{"0.0.0.0", nil, &wsConfig{Modules: []string{"admin"}}, false},
+		{"0.0.0.0", &httpConfig{Modules: []string{"admin"}, apiKeys: keys}, &wsConfig{Modules: []string{"admin"}}, false},
+		{"", &httpConfig{Modules: []string{"admin"}}, nil, false},
+		{"127.0.0.1", &httpConfig{Modules: []string{"admin"}}, &wsConfig{Modules: []string{"admin"}}, true},
+		{"localhost", &httpConfig{Modules: []string{"admin"}}, nil, true},
+	}
+	for i, tt := range tests {
+		srv := newHTTPServer(log.Log, rpc.DefaultHTTPTimeouts)
+		assert.NoError(t, srv.setListenAddr(tt.host, 0))
+		if tt.http != nil {
+			assert.NoError(t, srv.enableRPC(nil, *tt.http))
+		}
+		if tt.ws != nil {
+			assert.NoError(t, srv.enableWS(nil, *tt.ws))
+		}
+		if have := srv.moduleAuthenticated("admin"); have != tt.want {
+			t.Errorf("test %d: authenticated mismatch: have %v, want %v", i, have, tt.want)
+		}
+	}
+}
+
 func createAndStartServer(t *testing.T, conf *httpConfig, ws bool, wsConf *wsConfig) *httpServer {
 	t.Helper()
 
@@ -0,0 +1,131 @@
+// Copyright 2023 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package node
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// SubsystemStop is the outcome of stopping a single subsystem of the node.
+type SubsystemStop struct {
+	Name     string        // Name of the subsystem
+	Duration time.Duration // Time it took to stop
+	Err      error         // Error returned while stopping, if any
+}
+
+// ShutdownReport describes the progress and outcome of stopping the node, so
+// that a shutdown which failed or hung can be traced to the subsystem at fault.
+type ShutdownReport struct {
+	Started       time.Time       // Time the shutdown began (zero if not started)
+	Stopped       []SubsystemStop // Subsystems stopped so far, in stop order
+	Stopping      string          // Subsystem currently being stopped, if any
+	StoppingSince time.Time       // Time the current subsystem began stopping
+	Done          bool            // Whether the shutdown completed
+}
+
+// Failed returns the subsystems which failed to stop cleanly.
+func (r ShutdownReport) Failed() []SubsystemStop {
+	var failed []SubsystemStop
+	for _, stop := range r.Stopped {
+		if stop.Err != nil {
+			failed = append(failed, stop)
+		}
+	}
+	return failed
+}
+
+// String generates a textual summary of the shutdown.
+func (r ShutdownReport) String() string {
+	parts := make([]string, 0, len(r.Stopped)+1)
+	for _, stop := range r.Stopped {
+		if stop.Err != nil {
+			parts = append(parts, fmt.Sprintf("%s: %v (%v)", stop.Name, stop.Err, stop.Duration))
+		} else {
+			parts = append(parts, fmt.Sprintf("%s: ok (%v)", stop.Name, stop.Duration))
+		}
+	}
+	if r.Stopping != "" {
+		parts = append(parts, fmt.Sprintf("%s: stopping (%v)", r.Stopping, time.Since(r.StoppingSince)))
+	}
+	return strings.Join(parts, ", ")
+}
+
+// ShutdownReport returns the progress of stopping the node. It may be called
+// while the node is being stopped, in which case the report tells which
+// subsystem is being waited for.
+func (n *Node) ShutdownReport() ShutdownReport {
+	n.shutdownLock.Lock()
+	defer n.shutdownLock.Unlock()
+
+	report := n.shutdown
+	report.Stopped = append([]SubsystemStop(nil), n.shutdown.Stopped...)
+	return report
+}
+
+// Stopping returns a channel which is closed once the node begins to shut down,
+// whoever initiated the shutdown.
+func (n *Node) Stopping() <-chan struct{} {
+	return n.stopping
+}
+
+// beginShutdown marks the start of the shutdown, if not done yet. It must be
+// called with the shutdown lock held.
+func (n *Node) beginShutdown(now time.Time) {
+	if n.shutdown.Started.IsZero() {
+		n.shutdown.Started = now
+		close(n.stopping)
+	}
+}
+
+// stopSubsystem runs the stop function of a subsystem, recording its duration
+// and outcome in the shutdown report.
+func (n *Node) stopSubsystem(name string, stop func() error) error {
+	start := time.Now()
+
+	n.shutdownLock.Lock()
+	n.beginShutdown(start)
+	n.shutdown.Stopping = name
+	n.shutdown.StoppingSince = start
+	n.shutdownLock.Unlock()
+
+	err := stop()
+	elapsed := time.Since(start)
+	if err != nil {
+		n.log.Error("Failed to stop subsystem", "name", name, "elapsed", elapsed, "err", err)
+	} else {
+		n.log.Debug("Stopped subsystem", "name", name, "elapsed", elapsed)
+	}
+
+	n.shutdownLock.Lock()
+	n.shutdown.Stopping = ""
+	n.shutdown.StoppingSince = time.Time{}
+	n.shutdown.Stopped = append(n.shutdown.Stopped, SubsystemStop{Name: name, Duration: elapsed, Err: err})
+	n.shutdownLock.Unlock()
+
+	return err
+}
+
+// finishShutdown marks the shutdown as completed.
+func (n *Node) finishShutdown() {
+	n.shutdownLock.Lock()
+	defer n.shutdownLock.Unlock()
+
+	n.beginShutdown(time.Now())
+	n.shutdown.Done = true
+}
@@ -0,0 +1,78 @@
+// Copyright 2023 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package node
+
+import (
+	"errors"
+	"testing"
+)
+
+// Tests that the lifecycles failing to stop are listed in the shutdown report.
+func TestShutdownReportFailures(t *testing.T) {
+	stack, err := New(testNodeConfig())
+	if err != nil {
+		t.Fatalf("failed to create protocol stack: %v", err)
+	}
+	failure := errors.New("fail")
+	stack.RegisterLifecycle(&InstrumentedService{stop: failure})
+	stack.RegisterLifecycle(&NoopLifecycle{})
+
+	if err := stack.Start(); err != nil {
+		t.Fatalf("failed to start node: %v", err)
+	}
+	select {
+	case <-stack.Stopping():
+		t.Fatalf("node stopping before being closed")
+	default:
+	}
+	if err := stack.Close(); err == nil {
+		t.Fatalf("node closed without error")
+	}
+	select {
+	case <-stack.Stopping():
+	default:
+		t.Fatalf("node not stopping after being closed")
+	}
+	report := stack.ShutdownReport()
+	if !report.Done {
+		t.Errorf("shutdown not done")
+	}
+	if report.Stopping != "" {
+		t.Errorf("subsystem %q still stopping", report.Stopping)
+	}
+	failed := report.Failed()
+	if len(failed) != 1 {
+		t.Fatalf("failed subsystems mismatch: have %d, want 1", len(failed))
+	}
+	if failed[0].Name != "*node.InstrumentedService" || failed[0].Err != failure {
+		t.Errorf("failed subsystem mismatch: have %s (%v), want *node.InstrumentedService (%v)", failed[0].Name, failed[0].Err, failure)
+	}
+	// The subsystems are stopped in reverse order, between RPC and p2p networking
+	var names []string
+	for _, stop := range report.Stopped {
+		names = append(names, stop.Name)
+	}
+	want := []string{"rpc", "*node.NoopLifecycle", "*node.InstrumentedService", "p2p", "databases"}
+	if len(names) != len(want) {
+		t.Fatalf("stopped subsystems mismatch: have %v, want %v", names, want)
+	}
+	for i := range want {
+		if names[i] != want[i] {
+			t.Fatalf("stopped subsystems mismatch: have %v, want %v", names, want)
+		}
+	}
+}
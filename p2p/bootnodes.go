@@ -0,0 +1,110 @@
+// Copyright 2023 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package p2p
+
+import (
+	"sync"
+	"time"
+
+	"github.com/dominant-strategies/go-quai/metrics"
+	"github.com/dominant-strategies/go-quai/p2p/enode"
+)
+
+const (
+	// bootnodeCheckInterval is the time between two liveness checks of the
+	// bootstrap nodes.
+	bootnodeCheckInterval = 5 * time.Minute
+
+	// bootnodeCheckFailures is the number of consecutive failed liveness checks
+	// after which the fallback bootstrap nodes are switched to.
+	bootnodeCheckFailures = 3
+)
+
+var (
+	liveBootnodesGauge    = metrics.NewRegisteredGauge("p2p/bootnodes/live", nil)
+	bootnodeFailoverMeter = metrics.NewRegisteredMeter("p2p/bootnodes/failover", nil)
+)
+
+// bootnodeLoop periodically checks that enough bootstrap nodes are reachable,
+// and switches the discovery table to the fallback bootstrap nodes if they stay
+// unreachable, so that an outage of the bootstrap nodes doesn't silently keep
+// new nodes from joining the network.
+func (srv *Server) bootnodeLoop() {
+	defer srv.loopWG.Done()
+
+	var (
+		bootnodes  = srv.BootstrapNodes
+		failures   = 0
+		failedOver = false
+		ticker     = time.NewTicker(bootnodeCheckInterval)
+	)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+		case <-srv.quit:
+			return
+		}
+		live := srv.liveBootnodes(bootnodes)
+		liveBootnodesGauge.Update(int64(live))
+		if live >= srv.MinLiveBootnodes {
+			failures = 0
+			continue
+		}
+		failures++
+		srv.log.Warn("Too few bootstrap nodes reachable", "live", live, "want", srv.MinLiveBootnodes, "total", len(bootnodes), "failures", failures)
+		if failures < bootnodeCheckFailures {
+			continue
+		}
+		if failedOver || len(srv.FallbackBootstrapNodes) == 0 {
+			srv.log.Error("Bootstrap nodes unreachable, new peers may not be discovered", "live", live, "want", srv.MinLiveBootnodes)
+			continue
+		}
+		srv.log.Error("Bootstrap nodes unreachable, switching to fallback bootstrap nodes", "live", live, "want", srv.MinLiveBootnodes, "fallback", len(srv.FallbackBootstrapNodes))
+		if err := srv.ntab.SetBootnodes(srv.FallbackBootstrapNodes); err != nil {
+			srv.log.Error("Failed to switch to fallback bootstrap nodes", "err", err)
+			continue
+		}
+		bootnodeFailoverMeter.Mark(1)
+		bootnodes, failures, failedOver = srv.FallbackBootstrapNodes, 0, true
+	}
+}
+
+// liveBootnodes pings the given bootstrap nodes and returns how many replied.
+func (srv *Server) liveBootnodes(nodes []*enode.Node) int {
+	var (
+		live int
+		lock sync.Mutex
+		wg   sync.WaitGroup
+	)
+	for _, n := range nodes {
+		wg.Add(1)
+		go func(n *enode.Node) {
+			defer wg.Done()
+			if err := srv.ntab.Ping(n); err != nil {
+				srv.log.Debug("Bootstrap node unreachable", "id", n.ID(), "addr", n.IP(), "err", err)
+				return
+			}
+			lock.Lock()
+			live++
+			lock.Unlock()
+		}(n)
+	}
+	wg.Wait()
+	return live
+}
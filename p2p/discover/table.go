@@ -301,8 +301,12 @@ func (tab *Table) doRefresh(done chan struct{}) {
 }
 
 func (tab *Table) loadSeedNodes() {
+	tab.mutex.Lock()
+	nursery := tab.nursery
+	tab.mutex.Unlock()
+
 	seeds := wrapNodes(tab.db.QuerySeeds(seedCount, seedMaxAge))
-	seeds = append(seeds, tab.nursery...)
+	seeds = append(seeds, nursery...)
 	for i := range seeds {
 		seed := seeds[i]
 		log.Lazy(func() string { return time.Since(tab.db.LastPongReceived(seed.ID(), seed.IP())).String() }, "trace")
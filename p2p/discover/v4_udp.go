@@ -171,6 +171,19 @@ func (t *UDPv4) Close() {
 	})
 }
 
+// SetBootnodes replaces the bootstrap nodes of the table, and refreshes the
+// table from them.
+func (t *UDPv4) SetBootnodes(nodes []*enode.Node) error {
+	t.tab.mutex.Lock()
+	err := t.tab.setFallbackNodes(nodes)
+	t.tab.mutex.Unlock()
+	if err != nil {
+		return err
+	}
+	t.tab.refresh()
+	return nil
+}
+
 // Resolve searches for a specific node with the given ID and tries to get the most recent
 // version of the node record for it. It returns n if the node could not be resolved.
 func (t *UDPv4) Resolve(n *enode.Node) *enode.Node {
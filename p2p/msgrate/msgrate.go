@@ -370,6 +370,15 @@ func (t *Trackers) TargetRoundTrip() time.Duration {
 	return time.Duration(float64(t.roundtrip) * rttPushdownFactor)
 }
 
+// SetTimeoutLimit sets the maximum timeout allowance of requests, in place of the
+// default ttlLimit.
+func (t *Trackers) SetTimeoutLimit(limit time.Duration) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	t.OverrideTTLLimit = limit
+}
+
 // TargetTimeout returns the timeout allowance for a single request to finish
 // under. The timeout is proportional to the roundtrip, but also takes into
 // consideration the tracker's confidence in said roundtrip and scales it
@@ -64,6 +64,18 @@ const (
 	frameWriteTimeout = 20 * time.Second
 )
 
+// Default peer connection timeouts, applied to the zero Config fields.
+const (
+	DefaultDialTimeout      = defaultDialTimeout
+	DefaultHandshakeTimeout = handshakeTimeout
+	DefaultWriteTimeout     = frameWriteTimeout
+	DefaultReadTimeout      = frameReadTimeout
+
+	// MinReadTimeout is the read timeout below which idle connections would be
+	// torn down before the next ping keeps them alive.
+	MinReadTimeout = pingInterval
+)
+
 var errServerStopped = errors.New("server stopped")
 
 // Config holds Server options.
@@ -85,6 +97,15 @@ type Config struct {
 	// Setting DialRatio to zero defaults it to 3.
 	DialRatio int `toml:",omitempty"`
 
+	// DialTimeout, HandshakeTimeout, WriteTimeout and ReadTimeout bound the stages
+	// of a peer connection: establishing the TCP connection, completing the
+	// encryption handshake, writing a message and waiting for the next message
+	// (i.e. the longest a connection may stay idle). Zero uses the defaults.
+	DialTimeout      time.Duration `toml:",omitempty"`
+	HandshakeTimeout time.Duration `toml:",omitempty"`
+	WriteTimeout     time.Duration `toml:",omitempty"`
+	ReadTimeout      time.Duration `toml:",omitempty"`
+
 	// NoDiscovery can be used to disable the peer discovery mechanism.
 	// Disabling is useful for protocol debugging (manual topology).
 	NoDiscovery bool
@@ -106,6 +127,14 @@ type Config struct {
 	// protocol.
 	BootstrapNodesV5 []*enode.Node `toml:",omitempty"`
 
+	// FallbackBootstrapNodes replace the BootstrapNodes if fewer than
+	// MinLiveBootnodes of them stay reachable.
+	FallbackBootstrapNodes []*enode.Node `toml:",omitempty"`
+
+	// MinLiveBootnodes is the number of bootstrap nodes which must reply to the
+	// periodic liveness checks. Zero disables the checks.
+	MinLiveBootnodes int `toml:",omitempty"`
+
 	// Static nodes are used as pre-configured connections which are always
 	// maintained and re-connected on disconnects.
 	StaticNodes []*enode.Node
@@ -456,7 +485,7 @@ func (srv *Server) Start() (err error) {
 		return errors.New("Server.PrivateKey must be set to a non-nil key")
 	}
 	if srv.newTransport == nil {
-		srv.newTransport = newRLPX
+		srv.newTransport = srv.newTimedRLPX
 	}
 	if srv.listenFunc == nil {
 		srv.listenFunc = net.Listen
@@ -591,6 +620,11 @@ func (srv *Server) setupDiscovery() error {
 		}
 		srv.ntab = ntab
 		srv.discmix.AddSource(ntab.RandomNodes())
+
+		if srv.MinLiveBootnodes > 0 && len(srv.BootstrapNodes) > 0 {
+			srv.loopWG.Add(1)
+			go srv.bootnodeLoop()
+		}
 	}
 
 	// Discovery V5
@@ -628,7 +662,11 @@ func (srv *Server) setupDialScheduler() {
 		config.resolver = srv.ntab
 	}
 	if config.dialer == nil {
-		config.dialer = tcpDialer{&net.Dialer{Timeout: defaultDialTimeout}}
+		dialTimeout := defaultDialTimeout
+		if srv.DialTimeout != 0 {
+			dialTimeout = srv.DialTimeout
+		}
+		config.dialer = tcpDialer{&net.Dialer{Timeout: dialTimeout}}
 	}
 	srv.dialsched = newDialScheduler(config, srv.discmix, srv.SetupConn)
 	for _, n := range srv.StaticNodes {
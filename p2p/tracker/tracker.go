@@ -18,6 +18,7 @@ package tracker
 
 import (
 	"container/list"
+	"errors"
 	"fmt"
 	"sync"
 	"time"
@@ -40,9 +41,39 @@ const (
 	waitHistName = "p2p/wait"
 
 	// maxTrackedPackets is a huge number to act as a failsafe on the number of
-	// pending requests the node will track. It should never be hit unless an
-	// attacker figures out a way to spin requests.
-	maxTrackedPackets = 100000
+	// pending requests a single tracker will hold. It should never be hit unless
+	// an attacker figures out a way to spin requests.
+	maxTrackedPackets = 16384
+)
+
+var (
+	// ErrRequestCollision is returned if a request is tracked with an id that
+	// is already pending on the same tracker.
+	ErrRequestCollision = errors.New("request id collision")
+
+	// ErrTooManyRequests is returned if the tracker already holds the maximum
+	// permitted number of pending requests.
+	ErrTooManyRequests = errors.New("too many pending requests")
+
+	// ErrUnsolicitedResponse is returned if a response arrives for a request
+	// id which was never tracked or which already expired.
+	ErrUnsolicitedResponse = errors.New("unsolicited response")
+
+	// ErrMismatchedResponse is returned if a response arrives for a pending id,
+	// but from a different peer, protocol version or with a different message
+	// code than the original request expected.
+	ErrMismatchedResponse = errors.New("mismatched response")
+
+	// ErrTrackerStopped is returned if a request is tracked after the tracker
+	// has been stopped, and delivered for the requests pending when it stopped.
+	ErrTrackerStopped = errors.New("tracker stopped")
+
+	// ErrRequestTimeout is delivered for a request nobody answered in time.
+	ErrRequestTimeout = errors.New("request timed out")
+
+	// ErrRequestCanceled is delivered for a request cancelled before its
+	// response arrived.
+	ErrRequestCanceled = errors.New("request canceled")
 )
 
 // request tracks sent network requests which have not yet received a response.
@@ -53,12 +84,18 @@ type request struct {
 	reqCode uint64 // Protocol message code of the request
 	resCode uint64 // Protocol message code of the expected response
 
-	time   time.Time     // Timestamp when the request was made
-	expire *list.Element // Expiration marker to untrack it
+	time     time.Time     // Timestamp when the request was made
+	deadline time.Time     // Timestamp after which the request is considered lost
+	expire   *list.Element // Expiration marker to untrack it
+	done     chan error    // Channel completed once the request is answered or dropped
 }
 
-// Tracker is a pending network request tracker to measure how much time it takes
-// a remote peer to respond.
+// Tracker is a pending network request table, scoped to whoever owns it (in
+// practice a single peer connection). It associates every outstanding request
+// id with its expected response, a deadline and a channel notifying the waiter
+// of the outcome, garbage collects the requests nobody answered and rejects
+// responses to ids it doesn't know about, while measuring how much time it
+// takes a remote peer to respond.
 type Tracker struct {
 	protocol string        // Protocol capability identifier for the metrics
 	timeout  time.Duration // Global timeout after which to drop a tracked packet
@@ -66,6 +103,7 @@ type Tracker struct {
 	pending map[uint64]*request // Currently pending requests
 	expire  *list.List          // Linked list tracking the expiration order
 	wake    *time.Timer         // Timer tracking the expiration of the next item
+	stopped bool                // Flag whether the tracker was stopped
 
 	lock sync.Mutex // Lock protecting from concurrent updates
 }
@@ -82,42 +120,53 @@ func New(protocol string, timeout time.Duration) *Tracker {
 }
 
 // Track adds a network request to the tracker to wait for a response to arrive
-// or until the request it cancelled or times out.
-func (t *Tracker) Track(peer string, version uint, reqCode uint64, resCode uint64, id uint64) {
-	if !metrics.Enabled {
-		return
-	}
+// or until the request it cancelled or times out. The returned channel receives
+// exactly one value once that happens: nil if the response arrived, otherwise
+// the reason the request was dropped. An error is returned if the request cannot
+// be tracked, in which case it should not be sent either, as its response would
+// be rejected.
+func (t *Tracker) Track(peer string, version uint, reqCode uint64, resCode uint64, id uint64) (<-chan error, error) {
 	t.lock.Lock()
 	defer t.lock.Unlock()
 
-	// If there's a duplicate request, we've just random-collided (or more probably,
-	// we have a bug), report it. We could also add a metric, but we're not really
-	// expecting ourselves to be buggy, so a noisy warning should be enough.
+	if t.stopped {
+		return nil, ErrTrackerStopped
+	}
+	// If there's a duplicate request, we've just collided with a still pending
+	// id. Refuse to track it, the caller needs to pick a different one.
 	if _, ok := t.pending[id]; ok {
-		log.Error("Network request id collision", "protocol", t.protocol, "version", version, "code", reqCode, "id", id)
-		return
+		log.Warn("Network request id collision", "protocol", t.protocol, "version", version, "code", reqCode, "id", id)
+		return nil, ErrRequestCollision
 	}
 	// If we have too many pending requests, bail out instead of leaking memory
 	if pending := len(t.pending); pending >= maxTrackedPackets {
 		log.Error("Request tracker exceeded allowance", "pending", pending, "peer", peer, "protocol", t.protocol, "version", version, "code", reqCode)
-		return
+		return nil, ErrTooManyRequests
 	}
 	// Id doesn't exist yet, start tracking it
+	var (
+		now  = time.Now()
+		done = make(chan error, 1)
+	)
 	t.pending[id] = &request{
-		peer:    peer,
-		version: version,
-		reqCode: reqCode,
-		resCode: resCode,
-		time:    time.Now(),
-		expire:  t.expire.PushBack(id),
+		peer:     peer,
+		version:  version,
+		reqCode:  reqCode,
+		resCode:  resCode,
+		time:     now,
+		deadline: now.Add(t.timeout),
+		expire:   t.expire.PushBack(id),
+		done:     done,
+	}
+	if metrics.Enabled {
+		g := fmt.Sprintf("%s/%s/%d/%#02x", trackedGaugeName, t.protocol, version, reqCode)
+		metrics.GetOrRegisterGauge(g, nil).Inc(1)
 	}
-	g := fmt.Sprintf("%s/%s/%d/%#02x", trackedGaugeName, t.protocol, version, reqCode)
-	metrics.GetOrRegisterGauge(g, nil).Inc(1)
-
 	// If we've just inserted the first item, start the expiration timer
 	if t.wake == nil {
 		t.wake = time.AfterFunc(t.timeout, t.clean)
 	}
+	return done, nil
 }
 
 // clean is called automatically when a preset time passes without a response
@@ -126,8 +175,12 @@ func (t *Tracker) clean() {
 	t.lock.Lock()
 	defer t.lock.Unlock()
 
+	if t.stopped {
+		return
+	}
 	// Expire anything within a certain threshold (might be no items at all if
 	// we raced with the delivery)
+	now := time.Now()
 	for t.expire.Len() > 0 {
 		// Stop iterating if the next pending request is still alive
 		var (
@@ -135,18 +188,21 @@ func (t *Tracker) clean() {
 			id   = head.Value.(uint64)
 			req  = t.pending[id]
 		)
-		if time.Since(req.time) < t.timeout+5*time.Millisecond {
+		if now.Before(req.deadline.Add(5 * time.Millisecond)) {
 			break
 		}
 		// Nope, dead, drop it
 		t.expire.Remove(head)
 		delete(t.pending, id)
+		req.done <- ErrRequestTimeout
 
-		g := fmt.Sprintf("%s/%s/%d/%#02x", trackedGaugeName, t.protocol, req.version, req.reqCode)
-		metrics.GetOrRegisterGauge(g, nil).Dec(1)
+		if metrics.Enabled {
+			g := fmt.Sprintf("%s/%s/%d/%#02x", trackedGaugeName, t.protocol, req.version, req.reqCode)
+			metrics.GetOrRegisterGauge(g, nil).Dec(1)
 
-		m := fmt.Sprintf("%s/%s/%d/%#02x", lostMeterName, t.protocol, req.version, req.reqCode)
-		metrics.GetOrRegisterMeter(m, nil).Mark(1)
+			m := fmt.Sprintf("%s/%s/%d/%#02x", lostMeterName, t.protocol, req.version, req.reqCode)
+			metrics.GetOrRegisterMeter(m, nil).Mark(1)
+		}
 	}
 	t.schedule()
 }
@@ -158,48 +214,112 @@ func (t *Tracker) schedule() {
 		t.wake = nil
 		return
 	}
-	t.wake = time.AfterFunc(time.Until(t.pending[t.expire.Front().Value.(uint64)].time.Add(t.timeout)), t.clean)
+	t.wake = time.AfterFunc(time.Until(t.pending[t.expire.Front().Value.(uint64)].deadline), t.clean)
 }
 
-// Fulfil fills a pending request, if any is available, reporting on various metrics.
-func (t *Tracker) Fulfil(peer string, version uint, code uint64, id uint64) {
-	if !metrics.Enabled {
-		return
-	}
+// Fulfil fills a pending request, if any is available, reporting on various
+// metrics and returning how long the response took to arrive. If the response
+// does not match any live request, an error is returned and the response should
+// be dropped by the caller.
+func (t *Tracker) Fulfil(peer string, version uint, code uint64, id uint64) (time.Duration, error) {
 	t.lock.Lock()
 	defer t.lock.Unlock()
 
 	// If it's a non existing request, track as stale response
 	req, ok := t.pending[id]
 	if !ok {
-		m := fmt.Sprintf("%s/%s/%d/%#02x", staleMeterName, t.protocol, version, code)
-		metrics.GetOrRegisterMeter(m, nil).Mark(1)
-		return
+		if metrics.Enabled {
+			m := fmt.Sprintf("%s/%s/%d/%#02x", staleMeterName, t.protocol, version, code)
+			metrics.GetOrRegisterMeter(m, nil).Mark(1)
+		}
+		return 0, ErrUnsolicitedResponse
 	}
 	// If the response is funky, it might be some active attack
 	if req.peer != peer || req.version != version || req.resCode != code {
 		log.Warn("Network response id collision",
 			"have", fmt.Sprintf("%s:%s/%d:%d", peer, t.protocol, version, code),
-			"want", fmt.Sprintf("%s:%s/%d:%d", peer, t.protocol, req.version, req.resCode),
+			"want", fmt.Sprintf("%s:%s/%d:%d", req.peer, t.protocol, req.version, req.resCode),
 		)
-		return
+		return 0, ErrMismatchedResponse
 	}
 	// Everything matches, mark the request serviced and meter it
+	t.untrack(id, req)
+	req.done <- nil
+
+	elapsed := time.Since(req.time)
+	if metrics.Enabled {
+		h := fmt.Sprintf("%s/%s/%d/%#02x", waitHistName, t.protocol, req.version, req.reqCode)
+		sampler := func() metrics.Sample {
+			return metrics.ResettingSample(
+				metrics.NewExpDecaySample(1028, 0.015),
+			)
+		}
+		metrics.GetOrRegisterHistogramLazy(h, nil, sampler).Update(elapsed.Microseconds())
+	}
+	return elapsed, nil
+}
+
+// Cancel drops a pending request without waiting for its response, e.g. if
+// the request could not be sent out in the first place. Any response arriving
+// for it afterwards is treated as unsolicited.
+func (t *Tracker) Cancel(id uint64) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	if req, ok := t.pending[id]; ok {
+		t.untrack(id, req)
+		req.done <- ErrRequestCanceled
+	}
+}
+
+// untrack removes a pending request from the tracker, rescheduling the expiry
+// timer if needed. The caller must hold the lock.
+func (t *Tracker) untrack(id uint64, req *request) {
+	first := req.expire.Prev() == nil
+
 	t.expire.Remove(req.expire)
 	delete(t.pending, id)
-	if req.expire.Prev() == nil {
+	if first && t.wake != nil {
 		if t.wake.Stop() {
 			t.schedule()
 		}
 	}
-	g := fmt.Sprintf("%s/%s/%d/%#02x", trackedGaugeName, t.protocol, req.version, req.reqCode)
-	metrics.GetOrRegisterGauge(g, nil).Dec(1)
+	if metrics.Enabled {
+		g := fmt.Sprintf("%s/%s/%d/%#02x", trackedGaugeName, t.protocol, req.version, req.reqCode)
+		metrics.GetOrRegisterGauge(g, nil).Dec(1)
+	}
+}
 
-	h := fmt.Sprintf("%s/%s/%d/%#02x", waitHistName, t.protocol, req.version, req.reqCode)
-	sampler := func() metrics.Sample {
-		return metrics.ResettingSample(
-			metrics.NewExpDecaySample(1028, 0.015),
-		)
+// Pending returns the number of requests currently awaiting a response.
+func (t *Tracker) Pending() int {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	return len(t.pending)
+}
+
+// Stop terminates the tracker, dropping all pending requests. Any request
+// tracked or fulfilled afterwards is rejected.
+func (t *Tracker) Stop() {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	if t.stopped {
+		return
+	}
+	t.stopped = true
+	if t.wake != nil {
+		t.wake.Stop()
+		t.wake = nil
+	}
+	for id, req := range t.pending {
+		t.expire.Remove(req.expire)
+		delete(t.pending, id)
+		req.done <- ErrTrackerStopped
+
+		if metrics.Enabled {
+			g := fmt.Sprintf("%s/%s/%d/%#02x", trackedGaugeName, t.protocol, req.version, req.reqCode)
+			metrics.GetOrRegisterGauge(g, nil).Dec(1)
+		}
 	}
-	metrics.GetOrRegisterHistogramLazy(h, nil, sampler).Update(time.Since(req.time).Microseconds())
 }
@@ -0,0 +1,189 @@
+// Copyright 2021 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package tracker
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+// Tests that a tracked request can be fulfilled exactly once.
+func TestTrackFulfil(t *testing.T) {
+	tracker := New("test", time.Minute)
+	defer tracker.Stop()
+
+	if _, err := tracker.Track("peer", 1, 0x03, 0x04, 1); err != nil {
+		t.Fatalf("failed to track request: %v", err)
+	}
+	if _, err := tracker.Fulfil("peer", 1, 0x04, 1); err != nil {
+		t.Fatalf("failed to fulfil request: %v", err)
+	}
+	if _, err := tracker.Fulfil("peer", 1, 0x04, 1); !errors.Is(err, ErrUnsolicitedResponse) {
+		t.Fatalf("duplicate response error mismatch: have %v, want %v", err, ErrUnsolicitedResponse)
+	}
+	if pending := tracker.Pending(); pending != 0 {
+		t.Fatalf("pending request count mismatch: have %d, want 0", pending)
+	}
+}
+
+// Tests that responses which don't match the pending request are rejected
+// without consuming it.
+func TestFulfilMismatch(t *testing.T) {
+	tracker := New("test", time.Minute)
+	defer tracker.Stop()
+
+	if _, err := tracker.Track("peer", 1, 0x03, 0x04, 1); err != nil {
+		t.Fatalf("failed to track request: %v", err)
+	}
+	tests := []struct {
+		peer    string
+		version uint
+		code    uint64
+		id      uint64
+		err     error
+	}{
+		{"peer", 1, 0x04, 2, ErrUnsolicitedResponse},
+		{"other", 1, 0x04, 1, ErrMismatchedResponse},
+		{"peer", 2, 0x04, 1, ErrMismatchedResponse},
+		{"peer", 1, 0x06, 1, ErrMismatchedResponse},
+	}
+	for i, tt := range tests {
+		if _, err := tracker.Fulfil(tt.peer, tt.version, tt.code, tt.id); !errors.Is(err, tt.err) {
+			t.Errorf("test %d: error mismatch: have %v, want %v", i, err, tt.err)
+		}
+	}
+	if _, err := tracker.Fulfil("peer", 1, 0x04, 1); err != nil {
+		t.Fatalf("failed to fulfil request: %v", err)
+	}
+}
+
+// Tests that colliding request ids are refused.
+func TestTrackCollision(t *testing.T) {
+	tracker := New("test", time.Minute)
+	defer tracker.Stop()
+
+	if _, err := tracker.Track("peer", 1, 0x03, 0x04, 1); err != nil {
+		t.Fatalf("failed to track request: %v", err)
+	}
+	if _, err := tracker.Track("peer", 1, 0x05, 0x06, 1); !errors.Is(err, ErrRequestCollision) {
+		t.Fatalf("collision error mismatch: have %v, want %v", err, ErrRequestCollision)
+	}
+}
+
+// Tests that requests nobody answered are garbage collected after the timeout
+// and late responses to them are rejected.
+func TestTrackExpiry(t *testing.T) {
+	tracker := New("test", 50*time.Millisecond)
+	defer tracker.Stop()
+
+	for id := uint64(0); id < 3; id++ {
+		if _, err := tracker.Track("peer", 1, 0x03, 0x04, id); err != nil {
+			t.Fatalf("failed to track request %d: %v", id, err)
+		}
+	}
+	if _, err := tracker.Fulfil("peer", 1, 0x04, 0); err != nil {
+		t.Fatalf("failed to fulfil request: %v", err)
+	}
+	time.Sleep(200 * time.Millisecond)
+
+	if pending := tracker.Pending(); pending != 0 {
+		t.Fatalf("pending request count mismatch: have %d, want 0", pending)
+	}
+	if _, err := tracker.Fulfil("peer", 1, 0x04, 1); !errors.Is(err, ErrUnsolicitedResponse) {
+		t.Fatalf("late response error mismatch: have %v, want %v", err, ErrUnsolicitedResponse)
+	}
+}
+
+// Tests that cancelled and stopped requests are dropped.
+func TestCancelStop(t *testing.T) {
+	tracker := New("test", time.Minute)
+
+	for id := uint64(0); id < 3; id++ {
+		if _, err := tracker.Track("peer", 1, 0x03, 0x04, id); err != nil {
+			t.Fatalf("failed to track request %d: %v", id, err)
+		}
+	}
+	tracker.Cancel(0)
+	if _, err := tracker.Fulfil("peer", 1, 0x04, 0); !errors.Is(err, ErrUnsolicitedResponse) {
+		t.Fatalf("cancelled response error mismatch: have %v, want %v", err, ErrUnsolicitedResponse)
+	}
+	tracker.Stop()
+
+	if pending := tracker.Pending(); pending != 0 {
+		t.Fatalf("pending request count mismatch: have %d, want 0", pending)
+	}
+	if _, err := tracker.Track("peer", 1, 0x03, 0x04, 3); !errors.Is(err, ErrTrackerStopped) {
+		t.Fatalf("stopped tracker error mismatch: have %v, want %v", err, ErrTrackerStopped)
+	}
+}
+
+// Tests that the channel of every tracked request is completed exactly once
+// with the outcome of the request.
+func TestTrackDone(t *testing.T) {
+	tracker := New("test", 50*time.Millisecond)
+
+	done := make([]<-chan error, 4)
+	track := func(id int) {
+		ch, err := tracker.Track("peer", 1, 0x03, 0x04, uint64(id))
+		if err != nil {
+			t.Fatalf("failed to track request %d: %v", id, err)
+		}
+		done[id] = ch
+	}
+	for id := 0; id < 3; id++ {
+		track(id)
+	}
+	if _, err := tracker.Fulfil("peer", 1, 0x04, 0); err != nil {
+		t.Fatalf("failed to fulfil request: %v", err)
+	}
+	tracker.Cancel(1)
+
+	// Wait for request 2 to expire, then drop request 3 with the tracker
+	select {
+	case err := <-done[2]:
+		if !errors.Is(err, ErrRequestTimeout) {
+			t.Errorf("expired request outcome mismatch: have %v, want %v", err, ErrRequestTimeout)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("expired request not completed")
+	}
+	track(3)
+	tracker.Stop()
+
+	want := []error{nil, ErrRequestCanceled, nil, ErrTrackerStopped}
+	for id, ch := range done {
+		if id == 2 {
+			continue
+		}
+		select {
+		case err := <-ch:
+			if !errors.Is(err, want[id]) {
+				t.Errorf("request %d outcome mismatch: have %v, want %v", id, err, want[id])
+			}
+		default:
+			t.Errorf("request %d not completed", id)
+		}
+	}
+	for id, ch := range done {
+		select {
+		case err := <-ch:
+			t.Errorf("request %d completed twice: %v", id, err)
+		default:
+		}
+	}
+}
@@ -49,10 +49,35 @@ type rlpxTransport struct {
 	rmu, wmu sync.Mutex
 	wbuf     bytes.Buffer
 	conn     *rlpx.Conn
+
+	handshakeTimeout time.Duration
+	readTimeout      time.Duration
+	writeTimeout     time.Duration
 }
 
 func newRLPX(conn net.Conn, dialDest *ecdsa.PublicKey) transport {
-	return &rlpxTransport{conn: rlpx.NewConn(conn, dialDest)}
+	return &rlpxTransport{
+		conn:             rlpx.NewConn(conn, dialDest),
+		handshakeTimeout: handshakeTimeout,
+		readTimeout:      frameReadTimeout,
+		writeTimeout:     frameWriteTimeout,
+	}
+}
+
+// newTimedRLPX creates an RLPx transport using the timeouts configured on the
+// server, falling back to the defaults for the unset ones.
+func (srv *Server) newTimedRLPX(conn net.Conn, dialDest *ecdsa.PublicKey) transport {
+	t := newRLPX(conn, dialDest).(*rlpxTransport)
+	if srv.HandshakeTimeout != 0 {
+		t.handshakeTimeout = srv.HandshakeTimeout
+	}
+	if srv.ReadTimeout != 0 {
+		t.readTimeout = srv.ReadTimeout
+	}
+	if srv.WriteTimeout != 0 {
+		t.writeTimeout = srv.WriteTimeout
+	}
+	return t
 }
 
 func (t *rlpxTransport) ReadMsg() (Msg, error) {
@@ -60,7 +85,7 @@ func (t *rlpxTransport) ReadMsg() (Msg, error) {
 	defer t.rmu.Unlock()
 
 	var msg Msg
-	t.conn.SetReadDeadline(time.Now().Add(frameReadTimeout))
+	t.conn.SetReadDeadline(time.Now().Add(t.readTimeout))
 	code, data, wireSize, err := t.conn.Read()
 	if err == nil {
 		// Protocol messages are dispatched to subprotocol handlers asynchronously,
@@ -89,7 +114,7 @@ func (t *rlpxTransport) WriteMsg(msg Msg) error {
 	}
 
 	// Write the message.
-	t.conn.SetWriteDeadline(time.Now().Add(frameWriteTimeout))
+	t.conn.SetWriteDeadline(time.Now().Add(t.writeTimeout))
 	size, err := t.conn.Write(msg.Code, t.wbuf.Bytes())
 	if err != nil {
 		return err
@@ -127,7 +152,7 @@ func (t *rlpxTransport) close(err error) {
 }
 
 func (t *rlpxTransport) doEncHandshake(prv *ecdsa.PrivateKey) (*ecdsa.PublicKey, error) {
-	t.conn.SetDeadline(time.Now().Add(handshakeTimeout))
+	t.conn.SetDeadline(time.Now().Add(t.handshakeTimeout))
 	return t.conn.Handshake(prv)
 }
 
@@ -402,6 +402,70 @@ func (ec *Client) NonceAt(ctx context.Context, account common.Address, blockNumb
 	return uint64(result), err
 }
 
+// AccountResult is the result of a GetProof operation.
+type AccountResult struct {
+	Address      common.Address  `json:"address"`
+	AccountProof []string        `json:"accountProof"`
+	Balance      *big.Int        `json:"balance"`
+	CodeHash     common.Hash     `json:"codeHash"`
+	Nonce        uint64          `json:"nonce"`
+	StorageHash  common.Hash     `json:"storageHash"`
+	StorageProof []StorageResult `json:"storageProof"`
+}
+
+// StorageResult provides a proof for a key-value pair.
+type StorageResult struct {
+	Key   string   `json:"key"`
+	Value *big.Int `json:"value"`
+	Proof []string `json:"proof"`
+}
+
+// GetProof returns the account and storage values of the specified account
+// including the Merkle-proof. The block number can be nil, in which case the
+// proof is taken from the latest known block. Proofs are only served by zone
+// chains, as the dom chains do not hold any state.
+func (ec *Client) GetProof(ctx context.Context, account common.Address, keys []string, blockNumber *big.Int) (*AccountResult, error) {
+	type storageResult struct {
+		Key   string       `json:"key"`
+		Value *hexutil.Big `json:"value"`
+		Proof []string     `json:"proof"`
+	}
+	type accountResult struct {
+		Address      common.Address  `json:"address"`
+		AccountProof []string        `json:"accountProof"`
+		Balance      *hexutil.Big    `json:"balance"`
+		CodeHash     common.Hash     `json:"codeHash"`
+		Nonce        hexutil.Uint64  `json:"nonce"`
+		StorageHash  common.Hash     `json:"storageHash"`
+		StorageProof []storageResult `json:"storageProof"`
+	}
+	if keys == nil {
+		keys = []string{}
+	}
+	var res accountResult
+	if err := ec.c.CallContext(ctx, &res, "quai_getProof", account, keys, toBlockNumArg(blockNumber)); err != nil {
+		return nil, err
+	}
+	// Turn hexutils back to normal datatypes
+	storageResults := make([]StorageResult, 0, len(res.StorageProof))
+	for _, st := range res.StorageProof {
+		storageResults = append(storageResults, StorageResult{
+			Key:   st.Key,
+			Value: st.Value.ToInt(),
+			Proof: st.Proof,
+		})
+	}
+	return &AccountResult{
+		Address:      res.Address,
+		AccountProof: res.AccountProof,
+		Balance:      res.Balance.ToInt(),
+		Nonce:        uint64(res.Nonce),
+		CodeHash:     res.CodeHash,
+		StorageHash:  res.StorageHash,
+		StorageProof: storageResults,
+	}, nil
+}
+
 // Pending State
 
 // PendingBalanceAt returns the wei balance of the given account in the pending state.